@@ -2,23 +2,35 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/health"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
-	TermOnDemand   string = "JRTCKXETXF"
-	TermPerHour    string = "6YS6EN2CT7"
-	HealthEndpoint string = "global.health.amazonaws.com"
+	TermOnDemand        string = "JRTCKXETXF"
+	TermPerHour         string = "6YS6EN2CT7"
+	HealthEndpoint      string = "global.health.amazonaws.com"
+	HealthActiveRegion  string = "us-east-1"
+	HealthPassiveRegion string = "us-east-2"
 )
 
+var healthFailoverRegions = map[string]string{
+	HealthActiveRegion:  HealthPassiveRegion,
+	HealthPassiveRegion: HealthActiveRegion,
+}
+
 type Pricing struct {
 	Product     Product
 	ServiceCode string
@@ -59,18 +71,127 @@ func (m *Metrics) NewHealthClient(ctx context.Context) {
 	// Passive: us-east-2
 	// When theres an incident in us-east-1 AWS can change the endpoint to us-east-2 but, AFAIK you have to manage this yourself
 	// AWS Health Aware implementation also do something like this...
-	cname, err := net.LookupCNAME(HealthEndpoint)
+	region, err := resolveHealthRegion()
 	if err != nil {
-		panic(err.Error())
+		m.recordError(ctx, "ResolveHealthEndpoint", err)
+		log.Warnf("Using the AWS Health active region %s", HealthActiveRegion)
+		region = HealthActiveRegion
+	} else {
+		m.endpoint.resolved = region
 	}
 
-	cname = strings.TrimSuffix(cname, ".")
-	region := strings.Split(cname, ".")[1]
+	m.endpoint.resolvedAt = time.Now()
+	m.setHealthRegion(region)
+}
+
+// refreshHealthEndpoint resolves the AWS Health endpoint again and switches to the
+// resolved region if AWS changed it or we have failed over from it
+func (m *Metrics) refreshHealthEndpoint(ctx context.Context) {
+	if time.Since(m.endpoint.resolvedAt) < m.endpointRefresh {
+		return
+	}
+
+	m.endpoint.resolvedAt = time.Now()
+
+	region, err := resolveHealthRegion()
+	if err != nil {
+		m.recordError(ctx, "ResolveHealthEndpoint", err)
+		return
+	}
+
+	if region != m.endpoint.resolved {
+		log.Infof("AWS Health endpoint changed [previous=%s, current=%s]", m.endpoint.resolved, region)
+		m.endpoint.resolved = region
+	}
+
+	// a failover is only kept until the next refresh, it fails over again if the region is still unavailable
+	if current := m.endpoint.Region(); region != current {
+		log.Infof("Switching to the resolved AWS Health endpoint [previous=%s, current=%s]", current, region)
+		m.setHealthRegion(region)
+	}
+}
+
+// failoverHealthEndpoint switches to the other AWS Health region, it returns
+// false if the current region has no known failover region
+func (m *Metrics) failoverHealthEndpoint() bool {
+	current := m.endpoint.Region()
+
+	region, ok := healthFailoverRegions[current]
+	if !ok {
+		return false
+	}
+
+	log.Warnf("Failing over AWS Health endpoint [previous=%s, current=%s]", current, region)
+	m.setHealthRegion(region)
+
+	return true
+}
+
+// failoverError returns whether an error means the AWS Health region may be unavailable, i.e. the
+// endpoint could not be reached or returned a server error. Other errors (e.g. access denied,
+// validation or throttling) would be the same in the other region.
+func failoverError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// requests that could not be sent have a response error without status code
+	var responseErr *smithyhttp.ResponseError
+	if errors.As(err, &responseErr) && responseErr.Response != nil && responseErr.HTTPStatusCode() != 0 {
+		return responseErr.HTTPStatusCode() >= 500
+	}
 
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (m *Metrics) setHealthRegion(region string) {
 	cfg := m.awsconfig
 	cfg.Region = region
 
-	m.health = health.NewFromConfig(cfg, health.WithEndpointResolver(health.EndpointResolverFromURL(fmt.Sprintf("https://%s", cname))))
+	m.health = health.NewFromConfig(cfg, health.WithEndpointResolver(health.EndpointResolverFromURL(fmt.Sprintf("https://health.%s.amazonaws.com", region))))
+	m.endpoint.SetRegion(region)
+}
+
+// resolveHealthRegion returns the region currently used by the AWS Health global endpoint
+func resolveHealthRegion() (string, error) {
+	cname, err := net.LookupCNAME(HealthEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve AWS Health endpoint: %w", err)
+	}
+
+	cname = strings.TrimSuffix(cname, ".")
+	parts := strings.Split(cname, ".")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("unexpected AWS Health endpoint: %s", cname)
+	}
+
+	return parts[1], nil
+}
+
+// healthEndpoint is the AWS Health region in use, the region is read by the metrics
+// callback while the poller may change it
+type healthEndpoint struct {
+	mu     sync.RWMutex
+	region string
+
+	// last region returned by the DNS record and when it was resolved, only used by the poller
+	resolved   string
+	resolvedAt time.Time
+}
+
+func (e *healthEndpoint) Region() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.region
+}
+
+func (e *healthEndpoint) SetRegion(region string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.region = region
 }
 
 func newAWSConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+// EventChanges is what changed in an event since the last notification sent for it
+type EventChanges struct {
+	// PreviousStatus is set when the status changed
+	PreviousStatus healthTypes.EventStatusCode `json:"previousStatus,omitempty"`
+	NewAccounts    []string                    `json:"newAccounts,omitempty"`
+	NewResources   []string                    `json:"newResources,omitempty"`
+	// Description is the text added to the description, or the whole description if it was rewritten
+	Description string `json:"description,omitempty"`
+}
+
+func (c *EventChanges) empty() bool {
+	return c.PreviousStatus == "" && len(c.NewAccounts) == 0 && len(c.NewResources) == 0 && c.Description == ""
+}
+
+// Summary describes the changes in a few lines, e.g. to be displayed next to the event
+func (c *EventChanges) Summary() string {
+	var lines []string
+
+	if c.PreviousStatus != "" {
+		lines = append(lines, fmt.Sprintf("Status changed from %s", c.PreviousStatus))
+	}
+
+	if len(c.NewAccounts) > 0 {
+		lines = append(lines, fmt.Sprintf("New account(s): %s", strings.Join(c.NewAccounts, ",")))
+	}
+
+	if len(c.NewResources) > 0 {
+		lines = append(lines, fmt.Sprintf("New resource(s): %s", strings.Join(c.NewResources, ",")))
+	}
+
+	if c.Description != "" {
+		lines = append(lines, "Description updated")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// eventChanges compares the event with the last notification sent for it, it returns false when
+// nothing visible changed, and nil changes when the event was never notified
+func (m *Metrics) eventChanges(e HealthEvent) (*EventChanges, bool) {
+	n, ok := m.checkpoint.Notified[*e.Arn]
+	if !ok {
+		return nil, true
+	}
+
+	if n.Digest == eventDigest(e) {
+		return nil, false
+	}
+
+	changes := &EventChanges{
+		NewAccounts:  newValues(n.Accounts, e.AffectedAccounts),
+		NewResources: newValues(n.Resources, entityValues(e.AffectedResources)),
+		Description:  descriptionChange(n.Description, eventDescription(e)),
+	}
+
+	if n.Status != e.Event.StatusCode {
+		changes.PreviousStatus = n.Status
+	}
+
+	return changes, !changes.empty()
+}
+
+// updates returns what should be displayed as the updates of the event, only the new text
+// of the description when the event was already notified
+func (e HealthEvent) updates() string {
+	if e.Changes != nil && e.Changes.Description != "" {
+		return e.Changes.Description
+	}
+
+	return eventDescription(e)
+}
+
+func eventDescription(e HealthEvent) string {
+	if e.EventDescription == nil {
+		return ""
+	}
+
+	return aws.ToString(e.EventDescription.LatestDescription)
+}
+
+func entityValues(resources []healthTypes.AffectedEntity) []string {
+	values := make([]string, 0, len(resources))
+	for _, r := range resources {
+		values = append(values, aws.ToString(r.EntityValue))
+	}
+
+	return values
+}
+
+// newValues returns the values of current that are not in previous, sorted
+func newValues(previous, current []string) []string {
+	known := make(map[string]bool, len(previous))
+	for _, v := range previous {
+		known[v] = true
+	}
+
+	var added []string
+	for _, v := range current {
+		if !known[v] {
+			added = append(added, v)
+			known[v] = true
+		}
+	}
+	sort.Strings(added)
+
+	return added
+}
+
+// descriptionChange returns the text added to the description, AWS Health usually appends
+// updates to the existing text, otherwise the whole description is returned
+func descriptionChange(previous, current string) string {
+	if previous == current {
+		return ""
+	}
+
+	if strings.HasPrefix(current, previous) {
+		return strings.TrimSpace(strings.TrimPrefix(current, previous))
+	}
+
+	if strings.HasSuffix(current, previous) {
+		return strings.TrimSpace(strings.TrimSuffix(current, previous))
+	}
+
+	return current
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+func TestDescriptionChange(t *testing.T) {
+	tests := []struct {
+		previous, current string
+		want              string
+	}{
+		{"same", "same", ""},
+		{"Initial text.", "Initial text.\n\nUpdate: fixed.", "Update: fixed."},
+		{"Initial text.", "Update: fixed.\n\nInitial text.", "Update: fixed."},
+		{"Initial text.", "Rewritten text.", "Rewritten text."},
+		{"", "New text.", "New text."},
+		{"Old text.", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := descriptionChange(tt.previous, tt.current); got != tt.want {
+			t.Errorf("descriptionChange(%q, %q) = %q, want %q", tt.previous, tt.current, got, tt.want)
+		}
+	}
+}
+
+func testNotifiedEvent(status healthTypes.EventStatusCode, updated time.Time, description string, accounts []string, resources ...string) HealthEvent {
+	e := testEvent("arn", status, accounts...)
+	e.Event.LastUpdatedTime = aws.Time(updated)
+	e.EventDescription = &healthTypes.EventDescription{LatestDescription: aws.String(description)}
+
+	for _, r := range resources {
+		e.AffectedResources = append(e.AffectedResources, healthTypes.AffectedEntity{EntityValue: aws.String(r)})
+	}
+
+	return e
+}
+
+func TestEventChanges(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	open := healthTypes.EventStatusCodeOpen
+	notified := testNotifiedEvent(open, t0, "Initial text.", []string{"1"}, "i-1")
+
+	tests := []struct {
+		name        string
+		e           HealthEvent
+		wantChanged bool
+		want        *EventChanges
+	}{
+		{
+			name:        "same event",
+			e:           testNotifiedEvent(open, t0, "Initial text.", []string{"1"}, "i-1"),
+			wantChanged: false,
+		},
+		{
+			name:        "only the last updated time changed",
+			e:           testNotifiedEvent(open, t1, "Initial text.", []string{"1"}, "i-1"),
+			wantChanged: false,
+			want:        &EventChanges{},
+		},
+		{
+			name:        "status changed",
+			e:           testNotifiedEvent(healthTypes.EventStatusCodeClosed, t1, "Initial text.", []string{"1"}, "i-1"),
+			wantChanged: true,
+			want:        &EventChanges{PreviousStatus: open},
+		},
+		{
+			name:        "new accounts and resources",
+			e:           testNotifiedEvent(open, t1, "Initial text.", []string{"3", "1", "2"}, "i-1", "i-3", "i-2"),
+			wantChanged: true,
+			want:        &EventChanges{NewAccounts: []string{"2", "3"}, NewResources: []string{"i-2", "i-3"}},
+		},
+		{
+			name:        "removed resources are not a change",
+			e:           testNotifiedEvent(open, t1, "Initial text.", []string{"1"}),
+			wantChanged: false,
+			want:        &EventChanges{},
+		},
+		{
+			name:        "description appended",
+			e:           testNotifiedEvent(open, t1, "Initial text. Update: fixed.", []string{"1"}, "i-1"),
+			wantChanged: true,
+			want:        &EventChanges{Description: "Update: fixed."},
+		},
+	}
+
+	for _, tt := range tests {
+		m := &Metrics{checkpoint: newCheckpoint()}
+		m.markNotified(notified)
+
+		got, changed := m.eventChanges(tt.e)
+		if changed != tt.wantChanged || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: eventChanges() = %+v, %v, want %+v, %v", tt.name, got, changed, tt.want, tt.wantChanged)
+		}
+	}
+}
+
+func TestEventChangesNotNotified(t *testing.T) {
+	m := &Metrics{checkpoint: newCheckpoint()}
+
+	got, changed := m.eventChanges(testEvent("arn", healthTypes.EventStatusCodeOpen, "1"))
+	if got != nil || !changed {
+		t.Errorf("eventChanges() = %+v, %v, want nil, true", got, changed)
+	}
+}
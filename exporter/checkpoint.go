@@ -0,0 +1,245 @@
+package exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	StateBackendNone      string = "none"
+	StateBackendFile      string = "file"
+	StateBackendConfigMap string = "configmap"
+)
+
+// Checkpoint is the state persisted between restarts
+type Checkpoint struct {
+	LastScrape time.Time                `json:"lastScrape"`
+	Notified   map[string]NotifiedEvent `json:"notified"`
+	// Notifiers is the state of each notifier that needs one, by notifier name
+	Notifiers map[string]json.RawMessage `json:"notifiers,omitempty"`
+	Silences  []Silence                  `json:"silences,omitempty"`
+}
+
+// NotifiedEvent is the digest and content of the last notification sent for an event
+type NotifiedEvent struct {
+	Digest     string                      `json:"digest"`
+	Status     healthTypes.EventStatusCode `json:"status"`
+	NotifiedAt time.Time                   `json:"notifiedAt"`
+
+	// content of the notification, to only notify about what changed, see eventChanges
+	Accounts    []string `json:"accounts,omitempty"`
+	Resources   []string `json:"resources,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// CheckpointStore is a backend that persists the exporter state
+type CheckpointStore interface {
+	// Load returns the last saved checkpoint or nil if there is none
+	Load(ctx context.Context) (*Checkpoint, error)
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+}
+
+func newCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Notified:  make(map[string]NotifiedEvent),
+		Notifiers: make(map[string]json.RawMessage),
+	}
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{path: path}
+}
+
+func (s *fileCheckpointStore) Load(ctx context.Context) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	checkpoint := newCheckpoint()
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("could not parse checkpoint file %s: %w", s.path, err)
+	}
+
+	return checkpoint, nil
+}
+
+func (s *fileCheckpointStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash does not leave a truncated checkpoint behind
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (m *Metrics) loadCheckpoint(ctx context.Context) error {
+	m.checkpoint = newCheckpoint()
+
+	if m.checkpointStore == nil {
+		return nil
+	}
+
+	checkpoint, err := m.checkpointStore.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if checkpoint == nil {
+		log.Info("No checkpoint found, starting from scratch")
+		return nil
+	}
+
+	if checkpoint.Notified == nil {
+		checkpoint.Notified = make(map[string]NotifiedEvent)
+	}
+
+	if checkpoint.Notifiers == nil {
+		checkpoint.Notifiers = make(map[string]json.RawMessage)
+	}
+
+	m.checkpoint = checkpoint
+	m.silences.Load(checkpoint.Silences)
+	if !checkpoint.LastScrape.IsZero() {
+		m.lastScrape = checkpoint.LastScrape
+	}
+
+	// the event store is not persisted, the events still open that were not updated while the
+	// exporter was down are loaded again without notifying them
+	if !m.backfill {
+		m.backfill = true
+		m.backfillNotify = false
+	}
+
+	log.Infof("Resuming from checkpoint [lastScrape=%s, notified=%d, silences=%d]", m.lastScrape, len(checkpoint.Notified), len(checkpoint.Silences))
+
+	return nil
+}
+
+func (m *Metrics) saveCheckpoint(ctx context.Context) {
+	m.checkpoint.LastScrape = m.lastScrape
+
+	// closed events are not updated anymore, no need to keep them forever
+	for arn, n := range m.checkpoint.Notified {
+		if n.Status == healthTypes.EventStatusCodeClosed && time.Since(n.NotifiedAt) > m.store.retention {
+			delete(m.checkpoint.Notified, arn)
+		}
+	}
+
+	m.checkpoint.Silences = m.silences.List(time.Now(), m.store.retention)
+
+	if m.checkpointStore == nil {
+		return
+	}
+
+	m.saveNotifiersState()
+
+	if err := m.checkpointStore.Save(ctx, m.checkpoint); err != nil {
+		log.WithError(err).Error("Could not save checkpoint")
+	}
+}
+
+// saveNotifiersState copies the state of the notifiers to the checkpoint
+func (m *Metrics) saveNotifiersState() {
+	for _, n := range m.notifiers {
+		stateful, ok := n.Notifier.(statefulNotifier)
+		if !ok {
+			continue
+		}
+
+		state, err := stateful.SaveState()
+		if err != nil {
+			log.WithError(err).Errorf("Could not save state of notifier %s", n.Name())
+			continue
+		}
+
+		m.checkpoint.Notifiers[n.Name()] = state
+	}
+}
+
+func (m *Metrics) markNotified(e HealthEvent) {
+	m.checkpoint.Notified[*e.Arn] = NotifiedEvent{
+		Digest:      eventDigest(e),
+		Status:      e.Event.StatusCode,
+		NotifiedAt:  time.Now(),
+		Accounts:    append([]string{}, e.AffectedAccounts...),
+		Resources:   entityValues(e.AffectedResources),
+		Description: eventDescription(e),
+	}
+}
+
+// markUnchanged remembers an update without visible changes, the content of the last
+// notification is kept so later updates are still compared with what was sent
+func (m *Metrics) markUnchanged(e HealthEvent) {
+	n, ok := m.checkpoint.Notified[*e.Arn]
+	if !ok {
+		return
+	}
+
+	n.Digest = eventDigest(e)
+	m.checkpoint.Notified[*e.Arn] = n
+}
+
+func eventDigest(e HealthEvent) string {
+	accounts := append([]string{}, e.AffectedAccounts...)
+	sort.Strings(accounts)
+
+	resources := entityValues(e.AffectedResources)
+	sort.Strings(resources)
+
+	var lastUpdated string
+	if e.Event.LastUpdatedTime != nil {
+		lastUpdated = e.Event.LastUpdatedTime.UTC().String()
+	}
+
+	h := sha256.New()
+	for _, field := range []string{
+		aws.ToString(e.Arn),
+		string(e.Event.StatusCode),
+		lastUpdated,
+		strings.Join(accounts, ","),
+		strings.Join(resources, ","),
+		eventDescription(e),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	checkpointConfigMapKey  string = "checkpoint.json"
+	serviceAccountNamespace string = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+type configMapCheckpointStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// NewConfigMapCheckpointStore persists the checkpoint in a Kubernetes ConfigMap, it must be
+// running inside the cluster and, if namespace is empty, uses the namespace of the pod
+func NewConfigMapCheckpointStore(namespace, name string) (CheckpointStore, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("could not detect current namespace, please specify one: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	return &configMapCheckpointStore{client: client, namespace: namespace, name: name}, nil
+}
+
+func (s *configMapCheckpointStore) Load(ctx context.Context) (*Checkpoint, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, ok := cm.Data[checkpointConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+
+	checkpoint := newCheckpoint()
+	if err := json.Unmarshal([]byte(data), checkpoint); err != nil {
+		return nil, fmt.Errorf("could not parse checkpoint from configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	return checkpoint, nil
+}
+
+func (s *configMapCheckpointStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+
+	cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+			Data:       map[string]string{checkpointConfigMapKey: string(data)},
+		}
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[checkpointConfigMapKey] = string(data)
+
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
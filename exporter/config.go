@@ -0,0 +1,329 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the part of the configuration that can be reloaded without restarting, it is
+// built from the flags and can be completed by a YAML or JSON file, see --config
+type Config struct {
+	// Regions to monitor, empty means all regions
+	Regions []string `json:"regions,omitempty"`
+
+	IgnoreEvents         []string `json:"ignoreEvents,omitempty"`
+	IgnoreResources      []string `json:"ignoreResources,omitempty"`
+	IgnoreResourceEvents []string `json:"ignoreResourceEvents,omitempty"`
+	IgnoreOUs            []string `json:"ignoreOUs,omitempty"`
+	// Suppress are suppression rule expressions, see SuppressionRule
+	Suppress []string `json:"suppress,omitempty"`
+
+	// Include and exclude lists applied to metrics and notifications, accounts are ids or names
+	IncludeAccounts   []string `json:"includeAccounts,omitempty"`
+	ExcludeAccounts   []string `json:"excludeAccounts,omitempty"`
+	IncludeServices   []string `json:"includeServices,omitempty"`
+	ExcludeServices   []string `json:"excludeServices,omitempty"`
+	IncludeCategories []string `json:"includeCategories,omitempty"`
+	ExcludeCategories []string `json:"excludeCategories,omitempty"`
+
+	// Notifiers are added to the ones configured by flags
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"`
+}
+
+// LoadConfigFile reads a configuration file, unknown fields are rejected
+func LoadConfigFile(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the parts of the configuration that are not validated when applied
+func (cfg Config) Validate() error {
+	for _, region := range cfg.Regions {
+		if strings.TrimSpace(region) == "" {
+			return fmt.Errorf("invalid empty region")
+		}
+	}
+
+	for _, n := range cfg.Notifiers {
+		if n.Type == "" {
+			return fmt.Errorf("notifier %q has no type", n.Name)
+		}
+	}
+
+	return nil
+}
+
+// merge returns the configuration with the settings of the file, the lists of the file replace
+// the ones set by flags except for notifiers that are added to them
+func (cfg Config) merge(file Config) Config {
+	if file.Regions != nil {
+		cfg.Regions = file.Regions
+	}
+
+	if file.IgnoreEvents != nil {
+		cfg.IgnoreEvents = file.IgnoreEvents
+	}
+
+	if file.IgnoreResources != nil {
+		cfg.IgnoreResources = file.IgnoreResources
+	}
+
+	if file.IgnoreResourceEvents != nil {
+		cfg.IgnoreResourceEvents = file.IgnoreResourceEvents
+	}
+
+	if file.IgnoreOUs != nil {
+		cfg.IgnoreOUs = file.IgnoreOUs
+	}
+
+	if file.Suppress != nil {
+		cfg.Suppress = file.Suppress
+	}
+
+	for _, list := range []struct{ cfg, file *[]string }{
+		{&cfg.IncludeAccounts, &file.IncludeAccounts},
+		{&cfg.ExcludeAccounts, &file.ExcludeAccounts},
+		{&cfg.IncludeServices, &file.IncludeServices},
+		{&cfg.ExcludeServices, &file.ExcludeServices},
+		{&cfg.IncludeCategories, &file.IncludeCategories},
+		{&cfg.ExcludeCategories, &file.ExcludeCategories},
+	} {
+		if *list.file != nil {
+			*list.cfg = *list.file
+		}
+	}
+
+	cfg.Notifiers = append(append([]NotifierConfig{}, cfg.Notifiers...), file.Notifiers...)
+
+	return cfg
+}
+
+// flagConfig returns the configuration set by flags
+func (m *Metrics) flagConfig(c *cli.Context) (Config, error) {
+	var cfg Config
+
+	if c.String("regions") != "all-regions" {
+		cfg.Regions = strings.Split(c.String("regions"), ",")
+	}
+
+	if len(c.String("ignore-events")) > 0 {
+		cfg.IgnoreEvents = strings.Split(c.String("ignore-events"), ",")
+	}
+
+	if len(c.String("ignore-resources")) > 0 {
+		cfg.IgnoreResources = strings.Split(c.String("ignore-resources"), ",")
+	}
+
+	if len(c.String("ignore-resource-event")) > 0 {
+		cfg.IgnoreResourceEvents = strings.Split(c.String("ignore-resource-event"), ",")
+	}
+
+	if len(c.String("ignore-ou")) > 0 {
+		cfg.IgnoreOUs = strings.Split(c.String("ignore-ou"), ",")
+	}
+
+	cfg.Suppress = c.StringSlice("suppress")
+
+	for _, list := range []struct {
+		flag string
+		cfg  *[]string
+	}{
+		{"include-accounts", &cfg.IncludeAccounts},
+		{"exclude-accounts", &cfg.ExcludeAccounts},
+		{"include-services", &cfg.IncludeServices},
+		{"exclude-services", &cfg.ExcludeServices},
+		{"include-categories", &cfg.IncludeCategories},
+		{"exclude-categories", &cfg.ExcludeCategories},
+	} {
+		if len(c.String(list.flag)) > 0 {
+			*list.cfg = strings.Split(c.String(list.flag), ",")
+		}
+	}
+
+	notifiers, err := m.notifierConfigs(c)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Notifiers = notifiers
+
+	return cfg, nil
+}
+
+// loadConfig returns the configuration of the flags completed by the config file, if any
+func (m *Metrics) loadConfig() (Config, error) {
+	if m.configPath == "" {
+		return m.baseConfig, nil
+	}
+
+	info, err := os.Stat(m.configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	file, err := LoadConfigFile(m.configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg := m.baseConfig.merge(file)
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s: %w", m.configPath, err)
+	}
+
+	m.configModTime = info.ModTime()
+
+	return cfg, nil
+}
+
+// applyConfig replaces the current configuration, nothing is changed if any notifier or rule is invalid
+func (m *Metrics) applyConfig(cfg Config) error {
+	suppressions, err := legacyRules(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, expression := range cfg.Suppress {
+		rule, err := ParseSuppressionRule(expression)
+		if err != nil {
+			return err
+		}
+
+		suppressions = append(suppressions, rule)
+	}
+
+	filter, err := newEventFilter(cfg)
+	if err != nil {
+		return err
+	}
+
+	// notifiers created again get the state of the current ones
+	m.saveNotifiersState()
+
+	notifiers := make([]filteredNotifier, 0, len(cfg.Notifiers))
+	names := make(map[string]bool, len(cfg.Notifiers))
+	for _, nc := range cfg.Notifiers {
+		if names[nc.name()] {
+			return fmt.Errorf("duplicate notifier name %q, notifiers of the same type require a unique name", nc.name())
+		}
+		names[nc.name()] = true
+
+		n, err := m.newFilteredNotifier(nc)
+		if err != nil {
+			return err
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	m.notifiers = notifiers
+	for _, n := range notifiers {
+		m.enableNotifierRequirements(n)
+	}
+
+	m.regions = sortedCopy(cfg.Regions)
+	m.suppressions = suppressions
+	m.eventFilter = filter
+	m.ignoreOU = sortedCopy(cfg.IgnoreOUs)
+
+	if len(m.ignoreOU) > 0 {
+		m.loadOrgUnits = true
+	}
+
+	return nil
+}
+
+// reloadConfig reads the config file again and applies it, the previous configuration
+// is kept if the file is invalid
+func (m *Metrics) reloadConfig(ctx context.Context) {
+	loadAccountTags, loadOrgUnits := m.loadAccountTags, m.loadOrgUnits
+
+	cfg, err := m.loadConfig()
+	if err == nil {
+		err = m.applyConfig(cfg)
+	}
+
+	if err != nil {
+		m.recordError(ctx, "ReloadConfig", err)
+		log.WithError(err).Error("Could not reload the configuration, keeping the previous one")
+		return
+	}
+
+	// load the organization data needed by the new notifiers or filters right away
+	if m.organizationEnabled && (loadAccountTags != m.loadAccountTags || loadOrgUnits != m.loadOrgUnits) {
+		m.loadOrganization(ctx)
+	}
+
+	// events already stored may be filtered out by the new configuration
+	m.pruneStore(time.Now())
+
+	log.Infof("Reloaded the configuration [file=%s, notifiers=%d]", m.configPath, len(m.notifiers))
+}
+
+// watchConfig asks the poller to reload the configuration on SIGHUP or when the
+// config file is modified, modTime is the modification time of the file already loaded
+func (m *Metrics) watchConfig(ctx context.Context, modTime time.Time) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var check <-chan time.Time
+	if m.configPath != "" && m.configCheckInterval > 0 {
+		ticker := time.NewTicker(m.configCheckInterval)
+		defer ticker.Stop()
+		check = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			signal.Stop(hup)
+			return
+		case <-hup:
+			log.Info("Received SIGHUP, reloading the configuration")
+		case <-check:
+			info, err := os.Stat(m.configPath)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+
+			log.Infof("Config file %s was modified, reloading the configuration", m.configPath)
+		}
+
+		// a reload already pending will read the latest file
+		select {
+		case m.reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func sortedCopy(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -0,0 +1,404 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// maximum duration of the connection to the SMTP server to send an email
+const smtpTimeout = 30 * time.Second
+
+// EmailNotifier sends AWS Health events by email, either one email per event or, when
+// digestInterval is set, a periodic digest grouping the events by account
+type EmailNotifier struct {
+	name     string
+	m        *Metrics
+	addr     string
+	host     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	interval time.Duration
+
+	lastDigest time.Time
+	// events waiting for the next digest, by recipients (empty for the default ones) and event ARN
+	queue map[string]map[string]HealthEvent
+}
+
+type emailData struct {
+	Title  string
+	Groups []emailGroup
+}
+
+type emailGroup struct {
+	Account string
+	Events  []emailEvent
+}
+
+type emailEvent struct {
+	Title       string
+	Resolved    bool
+	Fields      []emailField
+	Description string
+}
+
+type emailField struct {
+	Title string
+	Value string
+}
+
+var emailTextTemplate = template.Must(template.New("text").Parse(`{{ .Title }}
+{{ range .Groups }}
+== {{ .Account }} ==
+{{ range .Events }}
+{{ .Title }}
+{{ range .Fields }}{{ .Title }}: {{ .Value }}
+{{ end }}
+{{ .Description }}
+{{ end }}{{ end }}`))
+
+var emailHTMLTemplate = htmlTemplate.Must(htmlTemplate.New("html").Parse(`<html>
+<body style="font-family: sans-serif">
+<h2>{{ .Title }}</h2>
+{{ range .Groups }}
+<h3>{{ .Account }}</h3>
+{{ range .Events }}
+<div style="border-left: 4px solid {{ if .Resolved }}#18be52{{ else }}#d00000{{ end }}; padding-left: 8px; margin-bottom: 16px">
+<p><b>{{ .Title }}</b></p>
+<table>
+{{ range .Fields }}<tr><td><b>{{ .Title }}</b></td><td>{{ .Value }}</td></tr>
+{{ end }}</table>
+<p style="white-space: pre-wrap">{{ .Description }}</p>
+</div>
+{{ end }}{{ end }}
+</body>
+</html>`))
+
+// newEmailNotifier accepts the host, port, username, password, from, to (comma separated)
+// and digest-interval options
+func (m *Metrics) newEmailNotifier(name string, options map[string]string) (*EmailNotifier, error) {
+	n := EmailNotifier{
+		name:       name,
+		m:          m,
+		host:       options["host"],
+		from:       options["from"],
+		lastDigest: time.Now(),
+		queue:      make(map[string]map[string]HealthEvent),
+		to:         splitRecipients(options["to"]),
+	}
+
+	if n.host == "" || n.from == "" || len(n.to) == 0 {
+		return nil, fmt.Errorf("email notifier %s requires a host, from and to", name)
+	}
+
+	port := options["port"]
+	if port == "" {
+		port = "587"
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("invalid smtp port %q", port)
+	}
+	n.addr = net.JoinHostPort(n.host, port)
+
+	if options["username"] != "" {
+		n.auth = smtp.PlainAuth("", options["username"], options["password"], n.host)
+	}
+
+	if interval := options["digest-interval"]; interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email digest interval %q: %w", interval, err)
+		}
+		n.interval = d
+	}
+
+	return &n, nil
+}
+
+func (n *EmailNotifier) Name() string {
+	return n.name
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	return n.NotifyDestination(ctx, e, "")
+}
+
+// NotifyDestination sends the event to other recipients (comma separated)
+func (n *EmailNotifier) NotifyDestination(ctx context.Context, e HealthEvent, recipients string) error {
+	if n.interval > 0 {
+		if _, ok := n.queue[recipients]; !ok {
+			n.queue[recipients] = make(map[string]HealthEvent)
+		}
+
+		// replaces older updates of the same event, the changes of the older updates were
+		// not sent yet so the whole event is sent instead
+		if _, queued := n.queue[recipients][aws.ToString(e.Arn)]; queued {
+			e.Changes = nil
+		}
+		n.queue[recipients][aws.ToString(e.Arn)] = e
+		return nil
+	}
+
+	event := n.event(e)
+	return n.send(ctx, n.recipients(recipients), event.Title, emailData{
+		Title:  event.Title,
+		Groups: n.groups([]HealthEvent{e}),
+	})
+}
+
+// Batching returns true when the events are sent in a digest
+func (n *EmailNotifier) Batching() bool {
+	return n.interval > 0
+}
+
+// Flush sends the digest if the digest interval has elapsed, it returns the number of emails sent
+func (n *EmailNotifier) Flush(ctx context.Context) (int, error) {
+	if n.interval == 0 || time.Since(n.lastDigest) < n.interval {
+		return 0, nil
+	}
+
+	sent := 0
+	var failed error
+	for recipients, queue := range n.queue {
+		events := make([]HealthEvent, 0, len(queue))
+		for _, e := range queue {
+			events = append(events, e)
+		}
+
+		title := fmt.Sprintf("AWS Health digest: %d event(s) updated since %s", len(events), n.lastDigest.In(n.m.tz).Format(time.RFC1123))
+		err := n.send(ctx, n.recipients(recipients), title, emailData{Title: title, Groups: n.groups(events)})
+		if err != nil {
+			// keeping the queue so it is sent with the next digest
+			failed = err
+			continue
+		}
+
+		delete(n.queue, recipients)
+		sent++
+	}
+
+	if failed == nil {
+		n.lastDigest = time.Now()
+	}
+
+	return sent, failed
+}
+
+func (n *EmailNotifier) recipients(recipients string) []string {
+	if recipients == "" {
+		return n.to
+	}
+
+	return splitRecipients(recipients)
+}
+
+func splitRecipients(recipients string) []string {
+	var to []string
+	for _, r := range strings.Split(recipients, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			to = append(to, r)
+		}
+	}
+
+	return to
+}
+
+// groups returns the events grouped by affected account name, sorted by name
+func (n *EmailNotifier) groups(events []HealthEvent) []emailGroup {
+	byAccount := make(map[string][]emailEvent)
+
+	for _, e := range events {
+		if len(e.AffectedAccounts) == 0 {
+			byAccount["All accounts"] = append(byAccount["All accounts"], n.event(e))
+			continue
+		}
+
+		for _, account := range e.AffectedAccounts {
+			name := n.m.extractAccounts([]string{account})
+			byAccount[name] = append(byAccount[name], n.event(e.withAccounts([]string{account})))
+		}
+	}
+
+	groups := make([]emailGroup, 0, len(byAccount))
+	for account, events := range byAccount {
+		groups = append(groups, emailGroup{Account: account, Events: events})
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Account < groups[j].Account })
+
+	return groups
+}
+
+// event renders the same information as the slack notification
+func (n *EmailNotifier) event(e HealthEvent) emailEvent {
+	msg := n.m.eventMessage(e)
+
+	event := emailEvent{
+		Title:       msg.Title,
+		Resolved:    msg.Kind == MessageResolved,
+		Description: msg.Updates,
+	}
+
+	for _, f := range msg.Fields {
+		event.Fields = append(event.Fields, emailField{Title: f.Title, Value: f.Value})
+	}
+
+	return event
+}
+
+func (n *EmailNotifier) send(ctx context.Context, to []string, subject string, data emailData) error {
+	var text, html bytes.Buffer
+	if err := emailTextTemplate.Execute(&text, data); err != nil {
+		return err
+	}
+
+	if err := emailHTMLTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	body := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", text.Bytes()},
+		{"text/html; charset=UTF-8", html.Bytes()},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return err
+	}
+
+	return n.sendMail(ctx, to, msg.Bytes())
+}
+
+// sendMail does the same as smtp.SendMail with a timeout, so an unresponsive server does not
+// block the poller
+func (n *EmailNotifier) sendMail(ctx context.Context, to []string, msg []byte) error {
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s does not support authentication", n.host)
+		}
+
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// emailState is the digest state kept in the checkpoint
+type emailState struct {
+	Queue      map[string]map[string]HealthEvent `json:"queue"`
+	LastDigest time.Time                         `json:"lastDigest"`
+}
+
+// SaveState returns the events waiting for the next digest and when the last digest was sent, so
+// they are not lost on a restart and the digest is not delayed when the configuration is reloaded
+func (n *EmailNotifier) SaveState() (json.RawMessage, error) {
+	return json.Marshal(emailState{Queue: n.queue, LastDigest: n.lastDigest})
+}
+
+func (n *EmailNotifier) LoadState(state json.RawMessage) error {
+	var s emailState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+
+	if s.Queue != nil {
+		n.queue = s.Queue
+	}
+
+	n.lastDigest = s.LastDigest
+
+	return nil
+}
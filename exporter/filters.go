@@ -0,0 +1,292 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	ReasonAccountFilter  string = "account_filter"
+	ReasonServiceFilter  string = "service_filter"
+	ReasonCategoryFilter string = "category_filter"
+
+	// maximum number of accounts in an OrganizationEventFilter
+	maxFilterAccounts = 50
+	// maximum number of services in an EventFilter or OrganizationEventFilter
+	maxFilterServices = 10
+)
+
+// eventFilter are the include and exclude lists applied to both metrics and notifications,
+// empty include lists match everything
+type eventFilter struct {
+	// accounts accept both account ids and names
+	includeAccounts []string
+	excludeAccounts []string
+
+	includeServices []string
+	excludeServices []string
+
+	includeCategories []string
+	excludeCategories []string
+}
+
+func newEventFilter(cfg Config) (eventFilter, error) {
+	for _, category := range append(append([]string{}, cfg.IncludeCategories...), cfg.ExcludeCategories...) {
+		if !validCategory(category) {
+			return eventFilter{}, fmt.Errorf("unknown event category %q, valid categories are %v", category, healthTypes.EventTypeCategory("").Values())
+		}
+	}
+
+	return eventFilter{
+		includeAccounts:   sortedCopy(cfg.IncludeAccounts),
+		excludeAccounts:   sortedCopy(cfg.ExcludeAccounts),
+		includeServices:   sortedCopy(cfg.IncludeServices),
+		excludeServices:   sortedCopy(cfg.ExcludeServices),
+		includeCategories: sortedCopy(cfg.IncludeCategories),
+		excludeCategories: sortedCopy(cfg.ExcludeCategories),
+	}, nil
+}
+
+func validCategory(category string) bool {
+	for _, c := range healthTypes.EventTypeCategory("").Values() {
+		if string(c) == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterEvent returns the reason the event is filtered out, when filtering by account the returned
+// event only contains the included accounts and their resources
+func (m Metrics) filterEvent(e HealthEvent) (HealthEvent, string, bool) {
+	f := m.eventFilter
+
+	if reason, ok := f.matchEvent(*e.Event); !ok {
+		return e, reason, false
+	}
+
+	if len(f.includeAccounts) == 0 && len(f.excludeAccounts) == 0 {
+		return e, "", true
+	}
+
+	if len(e.AffectedAccounts) == 0 {
+		// events of a single account (not using the organizational view) have no affected accounts,
+		// in the organizational view they are public events which do not affect the included accounts,
+		// the API does not return them either when the included accounts are requested
+		if m.organizationEnabled && len(f.includeAccounts) > 0 {
+			return e, ReasonAccountFilter, false
+		}
+
+		return e, "", true
+	}
+
+	var accounts []string
+	for _, account := range e.AffectedAccounts {
+		if m.accountIncluded(account) {
+			accounts = append(accounts, account)
+		}
+	}
+
+	if len(accounts) == 0 {
+		return e, ReasonAccountFilter, false
+	}
+
+	if len(accounts) < len(e.AffectedAccounts) {
+		e = e.withAccounts(accounts)
+	}
+
+	return e, "", true
+}
+
+// matchEvent returns the reason the event is filtered out by its service or category
+func (f eventFilter) matchEvent(event healthTypes.Event) (string, bool) {
+	service := aws.ToString(event.Service)
+	if !matchAny(f.includeServices, service) || contains(f.excludeServices, service) {
+		return ReasonServiceFilter, false
+	}
+
+	category := string(event.EventTypeCategory)
+	if !matchAny(f.includeCategories, category) || contains(f.excludeCategories, category) {
+		return ReasonCategoryFilter, false
+	}
+
+	return "", true
+}
+
+func (m Metrics) accountIncluded(account string) bool {
+	f := m.eventFilter
+	name := m.accountNames[account]
+
+	if len(f.includeAccounts) > 0 && !contains(f.includeAccounts, account) && (name == "" || !contains(f.includeAccounts, name)) {
+		return false
+	}
+
+	if contains(f.excludeAccounts, account) || name != "" && contains(f.excludeAccounts, name) {
+		return false
+	}
+
+	return true
+}
+
+// regionIncluded returns whether the events of a region are polled, global events have the
+// global region which must be listed explicitly
+func (m Metrics) regionIncluded(region string) bool {
+	return len(m.regions) == 0 || contains(m.regions, region)
+}
+
+// filterAccountIds returns the ids of the included accounts to request from the API, the excluded
+// accounts are only removed from them: requesting the other accounts of the organization would miss
+// the public events and the accounts created since the organization was listed. It returns
+// false when an account name could not be resolved or there are too many accounts for the API filter.
+func (m Metrics) filterAccountIds() ([]string, bool) {
+	f := m.eventFilter
+	if len(f.includeAccounts) == 0 {
+		return nil, false
+	}
+
+	ids := make(map[string]string, len(m.accountNames))
+	for id, name := range m.accountNames {
+		ids[name] = id
+	}
+
+	var accounts []string
+	for _, account := range f.includeAccounts {
+		id := account
+		if !isAccountId(account) {
+			var ok bool
+			if id, ok = ids[account]; !ok {
+				return nil, false
+			}
+		}
+
+		if m.accountIncluded(id) && !contains(accounts, id) {
+			accounts = append(accounts, id)
+		}
+	}
+	sort.Strings(accounts)
+
+	// an empty list would not filter anything
+	if len(accounts) == 0 || len(accounts) > maxFilterAccounts {
+		return nil, false
+	}
+
+	return accounts, true
+}
+
+// filterServices returns the services to request from the API, include lists longer than the API
+// limit are only applied to the events returned, see skipEvent
+func (m Metrics) filterServices() []string {
+	if len(m.eventFilter.includeServices) > maxFilterServices {
+		return nil
+	}
+
+	return m.eventFilter.includeServices
+}
+
+// filterCategories returns the categories to request from the API, the excluded categories are
+// removed from the included ones or from all categories. The API does not support filtering by
+// the investigation category, nothing is requested when investigation events are wanted.
+func (m Metrics) filterCategories() []healthTypes.EventTypeCategory {
+	f := m.eventFilter
+	if len(f.includeCategories) == 0 && len(f.excludeCategories) == 0 {
+		return nil
+	}
+
+	var candidates []healthTypes.EventTypeCategory
+	if len(f.includeCategories) > 0 {
+		candidates = categories(f.includeCategories)
+	} else {
+		candidates = healthTypes.EventTypeCategory("").Values()
+	}
+
+	var filtered []healthTypes.EventTypeCategory
+	for _, c := range candidates {
+		if contains(f.excludeCategories, string(c)) {
+			continue
+		}
+
+		if c == healthTypes.EventTypeCategoryInvestigation {
+			return nil
+		}
+
+		filtered = append(filtered, c)
+	}
+
+	return filtered
+}
+
+// pushdownOrgFilter adds the include and exclude lists to the API filter so fewer events are returned
+func (m Metrics) pushdownOrgFilter(filter *healthTypes.OrganizationEventFilter) *healthTypes.OrganizationEventFilter {
+	filter.Services = m.filterServices()
+	filter.EventTypeCategories = m.filterCategories()
+
+	if accounts, ok := m.filterAccountIds(); ok {
+		filter.AwsAccountIds = accounts
+	}
+
+	return filter
+}
+
+// pushdownAccountFilter adds the include and exclude lists to the API filter so fewer events are returned
+func (m Metrics) pushdownAccountFilter(filter *healthTypes.EventFilter) *healthTypes.EventFilter {
+	filter.Services = m.filterServices()
+	filter.EventTypeCategories = m.filterCategories()
+
+	return filter
+}
+
+// skipEvent returns whether an event returned by the API can be ignored before it is enriched,
+// using the filters and suppression rules that do not depend on its accounts or entities
+func (m Metrics) skipEvent(event healthTypes.Event) (string, bool) {
+	e := HealthEvent{Arn: event.Arn, Event: &event}
+
+	if reason, ok := m.eventFilter.matchEvent(event); !ok {
+		return reason, true
+	}
+
+	// event level rules give the same result for every account and entity of the event
+	for _, rule := range m.suppressions {
+		if rule.eventLevel && rule.expr.eval(ruleTarget{event: &e}) {
+			return rule.Reason, true
+		}
+	}
+
+	return "", false
+}
+
+func categories(values []string) []healthTypes.EventTypeCategory {
+	var c []healthTypes.EventTypeCategory
+	for _, v := range values {
+		c = append(c, healthTypes.EventTypeCategory(v))
+	}
+
+	return c
+}
+
+func isAccountId(account string) bool {
+	if len(account) != 12 {
+		return false
+	}
+
+	for _, c := range account {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
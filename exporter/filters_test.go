@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+func TestFilterCategories(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []healthTypes.EventTypeCategory
+	}{
+		{
+			name: "no filter",
+			want: nil,
+		},
+		{
+			name: "included categories",
+			cfg:  Config{IncludeCategories: []string{"scheduledChange", "issue"}},
+			want: []healthTypes.EventTypeCategory{"issue", "scheduledChange"},
+		},
+		{
+			name: "excluded from the included categories",
+			cfg:  Config{IncludeCategories: []string{"issue", "scheduledChange"}, ExcludeCategories: []string{"issue"}},
+			want: []healthTypes.EventTypeCategory{"scheduledChange"},
+		},
+		{
+			name: "investigation cannot be requested",
+			cfg:  Config{IncludeCategories: []string{"issue", "investigation"}},
+			want: nil,
+		},
+		{
+			name: "excluded investigation",
+			cfg:  Config{IncludeCategories: []string{"issue", "investigation"}, ExcludeCategories: []string{"investigation"}},
+			want: []healthTypes.EventTypeCategory{"issue"},
+		},
+		{
+			name: "excluded categories only, investigation is wanted",
+			cfg:  Config{ExcludeCategories: []string{"accountNotification"}},
+			want: nil,
+		},
+		{
+			name: "excluded categories including investigation",
+			cfg:  Config{ExcludeCategories: []string{"accountNotification", "investigation"}},
+			want: []healthTypes.EventTypeCategory{"issue", "scheduledChange"},
+		},
+	}
+
+	for _, tt := range tests {
+		filter, err := newEventFilter(tt.cfg)
+		if err != nil {
+			t.Errorf("%s: newEventFilter() returned error: %v", tt.name, err)
+			continue
+		}
+
+		m := Metrics{eventFilter: filter}
+		if got := m.filterCategories(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: filterCategories() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterAccountIds(t *testing.T) {
+	accountNames := map[string]string{
+		"111111111111": "prod",
+		"222222222222": "dev",
+		"333333333333": "sandbox",
+	}
+
+	var many []string
+	for i := 0; i <= maxFilterAccounts; i++ {
+		many = append(many, fmt.Sprintf("%012d", i))
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		want   []string
+		wantOk bool
+	}{
+		{
+			name:   "no filter",
+			wantOk: false,
+		},
+		{
+			name:   "excluded accounts only are not requested",
+			cfg:    Config{ExcludeAccounts: []string{"111111111111"}},
+			wantOk: false,
+		},
+		{
+			name:   "ids and names",
+			cfg:    Config{IncludeAccounts: []string{"dev", "111111111111", "222222222222"}},
+			want:   []string{"111111111111", "222222222222"},
+			wantOk: true,
+		},
+		{
+			name:   "excluded from the included accounts",
+			cfg:    Config{IncludeAccounts: []string{"prod", "dev"}, ExcludeAccounts: []string{"dev"}},
+			want:   []string{"111111111111"},
+			wantOk: true,
+		},
+		{
+			name:   "unknown name",
+			cfg:    Config{IncludeAccounts: []string{"prod", "unknown"}},
+			wantOk: false,
+		},
+		{
+			name:   "all included accounts excluded",
+			cfg:    Config{IncludeAccounts: []string{"prod"}, ExcludeAccounts: []string{"111111111111"}},
+			wantOk: false,
+		},
+		{
+			name:   "too many accounts",
+			cfg:    Config{IncludeAccounts: many},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		filter, err := newEventFilter(tt.cfg)
+		if err != nil {
+			t.Errorf("%s: newEventFilter() returned error: %v", tt.name, err)
+			continue
+		}
+
+		m := Metrics{eventFilter: filter, accountNames: accountNames}
+		got, ok := m.filterAccountIds()
+		if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: filterAccountIds() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -2,14 +2,13 @@ package exporter
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/health"
 	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
 	log "github.com/sirupsen/logrus"
-	"github.com/slack-go/slack"
 )
 
 func (m *Metrics) HealthOrganizationEnabled(ctx context.Context) bool {
@@ -22,110 +21,106 @@ func (m *Metrics) HealthOrganizationEnabled(ctx context.Context) bool {
 	return false
 }
 
-func (m *Metrics) GetHealthEvents() []HealthEvent {
-	var tmp, events []HealthEvent
+func (m *Metrics) GetHealthEvents(ctx context.Context) ([]HealthEvent, error) {
+	var tmp []HealthEvent
+	var err error
 
 	if m.organizationEnabled {
-		tmp = m.GetOrgEvents()
+		tmp, err = m.GetOrgEvents(ctx)
 	} else {
-		tmp = m.GetAccountEvents()
+		tmp, err = m.GetAccountEvents(ctx)
 	}
 
+	if err != nil {
+		return nil, err
+	}
+
+	return m.processEvents(ctx, tmp, true), nil
+}
+
+// GetOpenHealthEvents returns all events that are currently open or upcoming, it is used
+// to seed the event state at startup
+func (m *Metrics) GetOpenHealthEvents(ctx context.Context, notify bool) ([]HealthEvent, error) {
+	var tmp []HealthEvent
+	var err error
+
+	if m.organizationEnabled {
+		tmp, err = m.GetOpenOrgEvents(ctx)
+	} else {
+		tmp, err = m.GetOpenAccountEvents(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.processEvents(ctx, tmp, notify), nil
+}
+
+func (m *Metrics) processEvents(ctx context.Context, tmp []HealthEvent, notify bool) []HealthEvent {
+	var events []HealthEvent
+
+	m.instruments.eventsFetched.Add(ctx, int64(len(tmp)))
+
 	for _, e := range tmp {
-		if ignoreEvents(m.ignoreEvents, *e.Event.EventTypeCode) {
+		e, reason, keep := m.applyFilters(e)
+		if !keep {
+			m.recordIgnored(ctx, reason)
+			m.filteredArns = append(m.filteredArns, *e.Arn)
 			continue
 		}
 
-		if ignoreResources(m.ignoreResources, e.AffectedResources) {
-			// only ignore this event if all resources are ignored
+		events = append(events, e)
+
+		if !notify {
 			continue
 		}
 
-		if ignoreResourceEvent(m.ignoreResourceEvent, e) {
+		if silence, ok := m.silenced(e, time.Now()); ok {
+			// not marked as notified so the next update after the silence expires is sent
+			log.Debugf("Event %s silenced by %s, not notifying", *e.Arn, silence.ID)
 			continue
 		}
 
-		events = append(events, e)
-		m.SendSlackNotification(e)
-		m.LogEvent(e)
-	}
-
-	return events
-}
+		changes, changed := m.eventChanges(e)
+		if !changed {
+			log.Debugf("No visible change in event %s, not notifying", *e.Arn)
+			m.markUnchanged(e)
+			continue
+		}
 
-func (m Metrics) LogEvent(e HealthEvent) {
-	if !m.logEvents {
-		return
-	}
-	msg := map[string]string{
-		"resources":  m.extractResources(e.AffectedResources),
-		"accounts":   m.extractAccounts(e.AffectedAccounts),
-		"service":    *e.Event.Service,
-		"region":     *e.Event.Region,
-		"status":     string(e.Event.StatusCode),
-		"Start Time": e.Event.StartTime.In(m.tz).String(),
-		"Event ARN":  fmt.Sprintf("`%s`", *e.Event.Arn),
-		"Updates":    *e.EventDescription.LatestDescription,
+		// not marking as notified if any notifier failed so a later update of this event is sent again
+		e.Changes = changes
+		if m.notify(ctx, e) {
+			m.markNotified(e)
+		}
 	}
 
-	j, _ := json.Marshal(msg)
-
-	log.WithFields(log.Fields{
-		"event": string(j),
-	}).Info()
-
+	return events
 }
-func (m Metrics) SendSlackNotification(e HealthEvent) {
-	if m.slackApi == nil {
-		return
-	}
 
-	resources := m.extractResources(e.AffectedResources)
-	accounts := m.extractAccounts(e.AffectedAccounts)
-
-	service := *e.Event.Service
-	region := *e.Event.Region
-	status := e.Event.StatusCode
-
-	var text, color string
-	attachmentFields := []slack.AttachmentField{
-		{Title: "Account(s)", Value: accounts, Short: true},
-		{Title: "Resource(s)", Value: resources, Short: true},
-		{Title: "Service", Value: service, Short: true},
-		{Title: "Region", Value: region, Short: true},
-		{Title: "Start Time", Value: e.Event.StartTime.In(m.tz).String(), Short: true},
-		{Title: "Status", Value: string(status), Short: true},
-		{Title: "Event ARN", Value: fmt.Sprintf("`%s`", *e.Event.Arn), Short: false},
-		{Title: "Updates", Value: *e.EventDescription.LatestDescription, Short: false},
+// applyFilters returns the reason the event is ignored, the returned event only contains the
+// accounts that are not filtered out
+func (m Metrics) applyFilters(e HealthEvent) (HealthEvent, string, bool) {
+	e, keep := m.ignoreOUs(e)
+	if !keep {
+		// all accounts of this event are in ignored OUs
+		return e, "ignore_ou", false
 	}
 
-	if status == healthTypes.EventStatusCodeClosed {
-		text = fmt.Sprintf(":heavy_check_mark:*[RESOLVED] The AWS Health issue with the %s service in the %s region is now resolved.*", service, region)
-		color = "18be52"
-		attachmentFields = append(attachmentFields[:6], attachmentFields[5:]...)
-		if e.Event.EndTime != nil {
-			attachmentFields[5] = slack.AttachmentField{Title: "End Time", Value: e.Event.EndTime.In(m.tz).String(), Short: true}
-		} else {
-			attachmentFields[5] = slack.AttachmentField{Title: "End Time", Value: "-", Short: true}
-		}
-	} else {
-		text = fmt.Sprintf(":rotating_light:*[NEW] AWS Health reported an issue with the %s service in the %s region.*", service, region)
-		color = "danger"
+	e, reason, keep := m.filterEvent(e)
+	if !keep {
+		return e, reason, false
 	}
 
-	attachment := slack.Attachment{
-		Color:  color,
-		Fields: attachmentFields,
+	// suppressions are evaluated on the accounts left by the filters only
+	if rule, ok := m.suppressed(e); ok {
+		// every affected entity and account matches a rule
+		log.Debugf("Event %s suppressed by rule %q", *e.Arn, rule.Expression)
+		return e, rule.Reason, false
 	}
 
-	_, _, err := m.slackApi.PostMessage(
-		m.slackChannel,
-		slack.MsgOptionText(text, false),
-		slack.MsgOptionAttachments(attachment),
-	)
-	if err != nil {
-		panic(err.Error())
-	}
+	return e, "", true
 }
 
 func (m Metrics) extractResources(resources []healthTypes.AffectedEntity) string {
@@ -157,68 +152,3 @@ func (m Metrics) extractAccounts(accounts []string) string {
 		return "All accounts in region"
 	}
 }
-
-func ignoreEvents(ignoredEvents []string, event string) bool {
-	for _, e := range ignoredEvents {
-		if e == event {
-			return true
-		}
-	}
-
-	return false
-}
-
-func ignoreResources(ignoredResources []string, resources []healthTypes.AffectedEntity) bool {
-	if len(ignoredResources) == 0 {
-		// empty ignore list
-		return false
-	}
-
-	size := len(resources)
-
-	for _, ignored := range ignoredResources {
-		for _, resource := range resources {
-			if *resource.EntityValue == ignored {
-				size -= 1
-			}
-		}
-	}
-
-	if size == 0 {
-		// all resources are ignored, ignoring entire alert
-		return true
-	}
-
-	// not all resources are ignored
-	return false
-}
-
-func ignoreResourceEvent(ignoredResourceEvent []string, event HealthEvent) bool {
-	if len(ignoredResourceEvent) == 0 {
-		// empty ignore list
-		return false
-	}
-
-	size := len(event.AffectedResources)
-	resourceIgnored := false
-
-	for _, ignored := range ignoredResourceEvent {
-		tmp := strings.Split(ignored, ":")
-		ignoredEvent, ignoredResource := tmp[0], tmp[1]
-
-		for _, resource := range event.AffectedResources {
-			if *resource.EntityValue == ignoredResource && *event.Event.EventTypeCode == ignoredEvent {
-				resourceIgnored = true
-				size -= 1
-			}
-		}
-	}
-
-	if resourceIgnored && size == 0 {
-		// all resources are ignored, ignoring entire alert
-		return true
-	}
-
-	// not all resources are ignored
-	return false
-}
@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	OutcomeSuccess string = "success"
+	OutcomeError   string = "error"
+)
+
+// instruments are the metrics about the exporter itself
+type instruments struct {
+	errors         metric.Int64Counter
+	apiCalls       metric.Int64Counter
+	pollDuration   metric.Float64Histogram
+	eventsFetched  metric.Int64Counter
+	eventsIgnored  metric.Int64Counter
+	notifications  metric.Int64Counter
+	lastPoll       metric.Float64ObservableGauge
+	organizationOn metric.Int64ObservableGauge
+	healthRegion   metric.Int64ObservableGauge
+	activeSilences metric.Int64ObservableGauge
+
+	lastSuccessfulPoll atomic.Int64
+}
+
+func (m *Metrics) registerInstruments(meter metric.Meter) error {
+	var err error
+	i := instruments{}
+
+	i.errors, err = meter.Int64Counter("exporter_errors", metric.WithDescription("Number of errors while calling AWS APIs or sending notifications"))
+	if err != nil {
+		return err
+	}
+
+	i.apiCalls, err = meter.Int64Counter("api_calls", metric.WithDescription("Number of AWS API calls by operation and outcome"))
+	if err != nil {
+		return err
+	}
+
+	i.pollDuration, err = meter.Float64Histogram("poll_duration", metric.WithDescription("Duration of AWS Health polls"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	i.eventsFetched, err = meter.Int64Counter("events_fetched", metric.WithDescription("Number of events returned by the AWS Health API"))
+	if err != nil {
+		return err
+	}
+
+	i.eventsIgnored, err = meter.Int64Counter("events_ignored", metric.WithDescription("Number of events ignored by reason"))
+	if err != nil {
+		return err
+	}
+
+	i.notifications, err = meter.Int64Counter("notifications", metric.WithDescription("Number of notifications by sink and outcome"))
+	if err != nil {
+		return err
+	}
+
+	i.lastPoll, err = meter.Float64ObservableGauge("last_successful_poll_timestamp", metric.WithDescription("Time of the last successful AWS Health poll"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	i.organizationOn, err = meter.Int64ObservableGauge("organization_view_enabled", metric.WithDescription("Whether AWS Health Organizational View is being used"))
+	if err != nil {
+		return err
+	}
+
+	i.healthRegion, err = meter.Int64ObservableGauge("endpoint_region", metric.WithDescription("AWS Health region currently in use"))
+	if err != nil {
+		return err
+	}
+
+	i.activeSilences, err = meter.Int64ObservableGauge("active_silences", metric.WithDescription("Number of silences currently stopping notifications"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		if last := i.lastSuccessfulPoll.Load(); last > 0 {
+			o.ObserveFloat64(i.lastPoll, unixSeconds(time.Unix(0, last)))
+		}
+
+		enabled := int64(0)
+		if m.organizationEnabled {
+			enabled = 1
+		}
+		o.ObserveInt64(i.organizationOn, enabled)
+
+		if region := m.endpoint.Region(); region != "" {
+			o.ObserveInt64(i.healthRegion, 1, metric.WithAttributes(attribute.Key("region").String(region)))
+		}
+
+		o.ObserveInt64(i.activeSilences, int64(m.silences.Active(time.Now())))
+
+		return nil
+	}, i.lastPoll, i.organizationOn, i.healthRegion, i.activeSilences)
+	if err != nil {
+		return err
+	}
+
+	m.instruments = &i
+
+	return nil
+}
+
+// recordError logs and counts a failed operation
+func (m Metrics) recordError(ctx context.Context, operation string, err error) {
+	log.WithError(err).Errorf("%s failed", operation)
+
+	if m.instruments == nil {
+		return
+	}
+
+	m.instruments.errors.Add(ctx, 1, metric.WithAttributes(attribute.Key("operation").String(operation)))
+}
+
+func (m Metrics) recordPoll(ctx context.Context, start time.Time, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	} else {
+		m.instruments.lastSuccessfulPoll.Store(time.Now().UnixNano())
+	}
+
+	m.instruments.pollDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attribute.Key("outcome").String(outcome)))
+}
+
+func (m Metrics) recordIgnored(ctx context.Context, reason string) {
+	m.instruments.eventsIgnored.Add(ctx, 1, metric.WithAttributes(attribute.Key("reason").String(reason)))
+}
+
+func (m Metrics) recordNotification(ctx context.Context, sink string, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+
+	m.instruments.notifications.Add(ctx, 1, metric.WithAttributes(
+		attribute.Key("sink").String(sink),
+		attribute.Key("outcome").String(outcome),
+	))
+}
+
+// recordAPICall is a middleware that counts every AWS API call made with the exporter AWS config
+func (m Metrics) recordAPICall(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ExporterAPICalls", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleInitialize(ctx, in)
+
+		outcome := OutcomeSuccess
+		if err != nil {
+			outcome = OutcomeError
+		}
+
+		m.instruments.apiCalls.Add(ctx, 1, metric.WithAttributes(
+			attribute.Key("service").String(awsmiddleware.GetServiceID(ctx)),
+			attribute.Key("operation").String(awsmiddleware.GetOperationName(ctx)),
+			attribute.Key("outcome").String(outcome),
+		))
+
+		return out, metadata, err
+	}), middleware.After)
+}
@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LogNotifier logs AWS Health events as JSON
+type LogNotifier struct {
+	name string
+	m    *Metrics
+}
+
+func (m *Metrics) newLogNotifier(name string) *LogNotifier {
+	return &LogNotifier{name: name, m: m}
+}
+
+func (n *LogNotifier) Name() string {
+	return n.name
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	m := n.m
+
+	msg := map[string]string{
+		"resources":  m.extractResources(e.AffectedResources),
+		"accounts":   m.extractAccounts(e.AffectedAccounts),
+		"service":    *e.Event.Service,
+		"region":     *e.Event.Region,
+		"status":     string(e.Event.StatusCode),
+		"Start Time": e.Event.StartTime.In(m.tz).String(),
+		"Event ARN":  fmt.Sprintf("`%s`", *e.Event.Arn),
+		"Updates":    e.updates(),
+	}
+
+	if e.Changes != nil {
+		msg["Changes"] = e.Changes.Summary()
+	}
+
+	j, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"event": string(j),
+	}).Info()
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	MessageNew      string = "NEW"
+	MessageUpdate   string = "UPDATE"
+	MessageResolved string = "RESOLVED"
+)
+
+// eventMessage is the content of the notification of an event, each notifier renders it in its own format
+type eventMessage struct {
+	// Kind is MessageNew, MessageUpdate or MessageResolved
+	Kind    string
+	Title   string
+	Fields  []messageField
+	Updates string
+}
+
+type messageField struct {
+	Title string
+	Value string
+	// Short fields can be displayed side by side
+	Short bool
+	// Code values are displayed as code by the notifiers supporting it
+	Code bool
+}
+
+func (m Metrics) eventMessage(e HealthEvent) eventMessage {
+	service := aws.ToString(e.Event.Service)
+	region := aws.ToString(e.Event.Region)
+	status := e.Event.StatusCode
+
+	msg := eventMessage{
+		Fields: []messageField{
+			{Title: "Account(s)", Value: m.extractAccounts(e.AffectedAccounts), Short: true},
+			{Title: "Resource(s)", Value: strings.Trim(m.extractResources(e.AffectedResources), "`"), Short: true, Code: len(e.AffectedResources) > 0},
+			{Title: "Service", Value: service, Short: true},
+			{Title: "Region", Value: region, Short: true},
+			{Title: "Start Time", Value: e.Event.StartTime.In(m.tz).String(), Short: true},
+		},
+		Updates: e.updates(),
+	}
+
+	if status == healthTypes.EventStatusCodeClosed {
+		msg.Kind = MessageResolved
+		msg.Title = fmt.Sprintf("[RESOLVED] The AWS Health issue with the %s service in the %s region is now resolved.", service, region)
+
+		endTime := "-"
+		if e.Event.EndTime != nil {
+			endTime = e.Event.EndTime.In(m.tz).String()
+		}
+		msg.Fields = append(msg.Fields, messageField{Title: "End Time", Value: endTime, Short: true})
+	} else if e.Changes != nil {
+		msg.Kind = MessageUpdate
+		msg.Title = fmt.Sprintf("[UPDATE] AWS Health updated the issue with the %s service in the %s region.", service, region)
+	} else {
+		msg.Kind = MessageNew
+		msg.Title = fmt.Sprintf("[NEW] AWS Health reported an issue with the %s service in the %s region.", service, region)
+	}
+
+	msg.Fields = append(msg.Fields,
+		messageField{Title: "Status", Value: string(status), Short: true},
+		messageField{Title: "Event ARN", Value: aws.ToString(e.Event.Arn), Code: true},
+	)
+
+	if e.Changes != nil {
+		msg.Fields = append(msg.Fields, messageField{Title: "Changes", Value: e.Changes.Summary()})
+	}
+
+	return msg
+}
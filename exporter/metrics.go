@@ -2,66 +2,198 @@ package exporter
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"sort"
-	"strings"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/slack-go/slack"
+	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
 func NewMetrics(ctx context.Context, meter metric.Meter, c *cli.Context) (*Metrics, error) {
-	m := Metrics{}
+	m := Metrics{
+		store:    newEventStore(c.Duration("closed-event-retention")),
+		endpoint: &healthEndpoint{},
+		silences: newSilenceStore(),
+	}
 
-	m.init(ctx, c)
+	err := m.registerInstruments(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.init(ctx, c)
+	if err != nil {
+		return nil, err
+	}
 
 	g, _ := meter.Int64ObservableGauge("event", metric.WithDescription("Status of AWS Health events"))
+	info, _ := meter.Int64ObservableGauge("event_info", metric.WithDescription("Information about AWS Health events"))
+	statusEnum, _ := meter.Int64ObservableGauge("event_status", metric.WithDescription("Status of AWS Health events, one series per status"))
+	startTime, _ := meter.Float64ObservableGauge("event_start_time", metric.WithDescription("Start time of AWS Health events"), metric.WithUnit("s"))
+	endTime, _ := meter.Float64ObservableGauge("event_end_time", metric.WithDescription("End time of AWS Health events"), metric.WithUnit("s"))
+	lastUpdatedTime, _ := meter.Float64ObservableGauge("event_last_updated_time", metric.WithDescription("Last time AWS Health events were updated"), metric.WithUnit("s"))
 	meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
-		events := m.GetHealthEvents()
+		events := m.store.Events()
+
+		// events with the same labels share a series, it is open while any of them is open or upcoming
+		series := make(map[eventSeries]int64)
 		for _, e := range events {
+			key := eventSeries{
+				region:   aws.ToString(e.Event.Region),
+				service:  aws.ToString(e.Event.Service),
+				scope:    string(e.Event.EventScopeCode),
+				category: string(e.Event.EventTypeCategory),
+				code:     aws.ToString(e.Event.EventTypeCode),
+				account:  e.Account,
+			}
+			if m.exportOU {
+				key.ou = e.OU
+			}
+
+			status := int64(1) // open or upcoming
+			if e.Event.StatusCode == healthTypes.EventStatusCodeClosed {
+				status = int64(0) // closed
+			}
+
+			if current, ok := series[key]; !ok || status > current {
+				series[key] = status
+			}
+		}
+
+		for key, status := range series {
 			attributes := metric.WithAttributes(
+				attribute.Key("region").String(key.region),
+				attribute.Key("service").String(key.service),
+				attribute.Key("scope").String(key.scope),
+				attribute.Key("category").String(key.category),
+				attribute.Key("code").String(key.code),
+			)
+
+			if len(key.account) > 0 && m.exportOU {
+				o.ObserveInt64(g, status, attributes, metric.WithAttributes(
+					attribute.Key("account").String(key.account),
+					attribute.Key("ou").String(key.ou),
+				))
+			} else if len(key.account) > 0 {
+				o.ObserveInt64(g, status, attributes, metric.WithAttributes(attribute.Key("account").String(key.account)))
+			} else {
+				o.ObserveInt64(g, status, attributes)
+			}
+		}
+
+		for _, e := range events {
+			o.ObserveInt64(info, 1, metric.WithAttributes(
+				attribute.Key("arn").String(aws.ToString(e.Arn)),
+				attribute.Key("account").String(e.Account),
 				attribute.Key("region").String(aws.ToString(e.Event.Region)),
 				attribute.Key("service").String(aws.ToString(e.Event.Service)),
 				attribute.Key("scope").String(string(e.Event.EventScopeCode)),
 				attribute.Key("category").String(string(e.Event.EventTypeCategory)),
 				attribute.Key("code").String(aws.ToString(e.Event.EventTypeCode)),
+				attribute.Key("status").String(string(e.Event.StatusCode)),
+			))
+
+			for _, statusCode := range e.Event.StatusCode.Values() {
+				value := int64(0)
+				if statusCode == e.Event.StatusCode {
+					value = 1
+				}
+
+				o.ObserveInt64(statusEnum, value, metric.WithAttributes(
+					attribute.Key("arn").String(aws.ToString(e.Arn)),
+					attribute.Key("account").String(e.Account),
+					attribute.Key("status").String(string(statusCode)),
+				))
+			}
+
+			timeAttributes := metric.WithAttributes(
+				attribute.Key("arn").String(aws.ToString(e.Arn)),
+				attribute.Key("account").String(e.Account),
+				attribute.Key("region").String(aws.ToString(e.Event.Region)),
+				attribute.Key("service").String(aws.ToString(e.Event.Service)),
+				attribute.Key("category").String(string(e.Event.EventTypeCategory)),
+				attribute.Key("code").String(aws.ToString(e.Event.EventTypeCode)),
 			)
 
-			status := int64(1) // open
-			if e.Event.StatusCode != "open" {
-				status = int64(0) // closed
+			if e.Event.StartTime != nil {
+				o.ObserveFloat64(startTime, unixSeconds(*e.Event.StartTime), timeAttributes)
 			}
 
-			if len(e.AffectedAccounts) > 0 {
-				for _, account := range e.AffectedAccounts {
-					o.ObserveInt64(g, status, attributes, metric.WithAttributes(attribute.Key("account").String(account)))
-				}
-			} else {
-				o.ObserveInt64(g, status, attributes)
+			if e.Event.EndTime != nil {
+				o.ObserveFloat64(endTime, unixSeconds(*e.Event.EndTime), timeAttributes)
+			}
+
+			if e.Event.LastUpdatedTime != nil {
+				o.ObserveFloat64(lastUpdatedTime, unixSeconds(*e.Event.LastUpdatedTime), timeAttributes)
 			}
 		}
 
 		return nil
-	}, g)
+	}, g, info, statusEnum, startTime, endTime, lastUpdatedTime)
+
+	if m.exportEntities {
+		eg, _ := meter.Int64ObservableGauge("affected_entity", metric.WithDescription("Entities affected by AWS Health events"))
+		dropped, _ := meter.Int64ObservableGauge("affected_entity_dropped", metric.WithDescription("Number of affected entities not exported because of --affected-entities-limit"))
+		meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			exported, skipped := 0, 0
+
+			// events and entities are sorted so the same entities are exported when the limit is reached
+			for _, e := range m.store.Events() {
+				for _, entity := range sortedEntities(e.AffectedResources) {
+					// events are stored per account, only export the entities of that account
+					if len(e.Account) > 0 && entity.AwsAccountId != nil && *entity.AwsAccountId != e.Account {
+						continue
+					}
+
+					if exported >= m.entitiesLimit {
+						skipped++
+						continue
+					}
+
+					o.ObserveInt64(eg, 1, metric.WithAttributes(
+						attribute.Key("region").String(aws.ToString(e.Event.Region)),
+						attribute.Key("service").String(aws.ToString(e.Event.Service)),
+						attribute.Key("code").String(aws.ToString(e.Event.EventTypeCode)),
+						attribute.Key("account").String(e.Account),
+						attribute.Key("entity_value").String(aws.ToString(entity.EntityValue)),
+						attribute.Key("entity_arn").String(aws.ToString(entity.EntityArn)),
+						attribute.Key("status_code").String(string(entity.StatusCode)),
+					))
+					exported++
+				}
+			}
+
+			if skipped > 0 {
+				log.Debugf("Affected entities limit reached, %d entities were not exported", skipped)
+			}
+			o.ObserveInt64(dropped, int64(skipped))
+
+			return nil
+		}, eg, dropped)
+	}
 
 	return &m, nil
 }
 
-func (m *Metrics) init(ctx context.Context, c *cli.Context) {
+func (m *Metrics) init(ctx context.Context, c *cli.Context) error {
 	cfg, err := newAWSConfig(ctx)
 
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
 	m.awsconfig = cfg
+	m.awsconfig.APIOptions = append(m.awsconfig.APIOptions, m.recordAPICall)
 
 	if len(c.String("assume-role")) > 0 {
 		stsclient := sts.NewFromConfig(m.awsconfig)
@@ -69,47 +201,98 @@ func (m *Metrics) init(ctx context.Context, c *cli.Context) {
 		m.awsconfig.Credentials = aws.NewCredentialsCache(creds)
 	}
 
+	m.endpointRefresh = c.Duration("health-endpoint-refresh")
 	m.NewHealthClient(ctx)
 
 	m.lastScrape = time.Now().Add(c.Duration("time-shift"))
+	m.retries = make(map[string]int)
+	m.pollInterval = c.Duration("poll-interval")
+	m.backfill = c.Bool("backfill")
+	m.backfillNotify = c.Bool("backfill-notify")
 
-	if len(c.String("slack-token")) > 0 && len(c.String("slack-channel")) > 0 {
-		m.slackToken = c.String("slack-token")
-		m.slackChannel = c.String("slack-channel")
-		m.slackApi = slack.New(m.slackToken)
+	switch c.String("state-backend") {
+	case StateBackendNone:
+	case StateBackendFile:
+		m.checkpointStore = NewFileCheckpointStore(c.String("state-file"))
+	case StateBackendConfigMap:
+		m.checkpointStore, err = NewConfigMapCheckpointStore(c.String("state-namespace"), c.String("state-configmap"))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown state backend: %s", c.String("state-backend"))
 	}
 
-	m.organizationEnabled = m.HealthOrganizationEnabled(ctx)
-	if m.organizationEnabled {
-		m.GetOrgAccountsName(ctx)
+	err = m.loadCheckpoint(ctx)
+	if err != nil {
+		return err
 	}
 
-	m.tz, err = time.LoadLocation(os.Getenv("TZ"))
+	m.httpClient = &http.Client{Timeout: 30 * time.Second}
+
+	m.loadAccountTags = c.Bool("load-account-tags")
+	m.loadOrgUnits = c.Bool("organizational-units")
+	m.organizationRefresh = c.Duration("organization-refresh-interval")
+
+	m.slackToken = c.String("slack-token")
+	m.configPath = c.String("config")
+	m.configCheckInterval = c.Duration("config-check-interval")
+	m.reload = make(chan struct{}, 1)
+
+	m.baseConfig, err = m.flagConfig(c)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
-	if c.String("regions") != "all-regions" {
-		m.regions = strings.Split(c.String("regions"), ",")
-		sort.Strings(m.regions)
+	config, err := m.loadConfig()
+	if err != nil {
+		return err
 	}
 
-	if len(c.String("ignore-events")) > 0 {
-		m.ignoreEvents = strings.Split(c.String("ignore-events"), ",")
-		sort.Strings(m.ignoreEvents)
+	err = m.applyConfig(config)
+	if err != nil {
+		return err
 	}
 
-	if len(c.String("ignore-resources")) > 0 {
-		m.ignoreResources = strings.Split(c.String("ignore-resources"), ",")
-		sort.Strings(m.ignoreResources)
+	m.organizationEnabled = m.HealthOrganizationEnabled(ctx)
+	if m.organizationEnabled {
+		m.loadOrganization(ctx)
 	}
 
-	if len(c.String("ignore-resource-event")) > 0 {
-		m.ignoreResourceEvent = strings.Split(c.String("ignore-resource-event"), ",")
-		sort.Strings(m.ignoreResourceEvent)
+	m.tz, err = time.LoadLocation(os.Getenv("TZ"))
+	if err != nil {
+		return err
 	}
 
-	if c.Bool("log-events") {
-		m.logEvents = true
-	}
+	m.exportEntities = c.Bool("export-affected-entities")
+	m.entitiesLimit = c.Int("affected-entities-limit")
+
+	// the ou label is only added by the flag, the OUs are also loaded for --ignore-ou and
+	// the ou filter of notifiers
+	m.exportOU = c.Bool("organizational-units")
+
+	return nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// eventSeries are the labels of the event gauge
+type eventSeries struct {
+	region, service, scope, category, code, account, ou string
+}
+
+// sortedEntities returns a copy of the entities sorted by value and ARN
+func sortedEntities(entities []healthTypes.AffectedEntity) []healthTypes.AffectedEntity {
+	sorted := append([]healthTypes.AffectedEntity{}, entities...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if aws.ToString(sorted[i].EntityValue) != aws.ToString(sorted[j].EntityValue) {
+			return aws.ToString(sorted[i].EntityValue) < aws.ToString(sorted[j].EntityValue)
+		}
+
+		return aws.ToString(sorted[i].EntityArn) < aws.ToString(sorted[j].EntityArn)
+	})
+
+	return sorted
 }
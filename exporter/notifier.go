@@ -0,0 +1,469 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	NotifierSlack     string = "slack"
+	NotifierWebhook   string = "webhook"
+	NotifierLog       string = "log"
+	NotifierTeams     string = "teams"
+	NotifierPagerDuty string = "pagerduty"
+	NotifierOpsgenie  string = "opsgenie"
+	NotifierEmail     string = "email"
+)
+
+// Notifier is a destination of AWS Health event notifications
+type Notifier interface {
+	// Name identifies this notifier in logs and metrics
+	Name() string
+	Notify(ctx context.Context, e HealthEvent) error
+}
+
+// statefulNotifier is implemented by notifiers that need to remember the events they were
+// notified about, the state is persisted with the checkpoint
+type statefulNotifier interface {
+	SaveState() (json.RawMessage, error)
+	LoadState(state json.RawMessage) error
+}
+
+// flushableNotifier is implemented by notifiers that batch events, Flush is called after every poll
+// and returns the number of notifications sent
+type flushableNotifier interface {
+	Flush(ctx context.Context) (int, error)
+	// Batching returns whether Notify only queues the events until they are flushed
+	Batching() bool
+}
+
+// NotifierConfig describes a notifier and which events it receives
+type NotifierConfig struct {
+	Type    string            `json:"type"`
+	Name    string            `json:"name,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+	Filter  NotifierFilter    `json:"filter,omitempty"`
+}
+
+// name returns the name of the notifier, it defaults to its type and must be unique as it identifies
+// the state of the notifier in the checkpoint
+func (cfg NotifierConfig) name() string {
+	if cfg.Name == "" {
+		return cfg.Type
+	}
+
+	return cfg.Name
+}
+
+// NotifierFilter limits the events sent to a notifier, empty lists match everything
+type NotifierFilter struct {
+	Services       []string `json:"services,omitempty"`
+	Regions        []string `json:"regions,omitempty"`
+	Categories     []string `json:"categories,omitempty"`
+	EventTypeCodes []string `json:"eventTypeCodes,omitempty"`
+	// Accounts accepts both account ids and names
+	Accounts []string `json:"accounts,omitempty"`
+	// OUs are organizational unit path patterns, see matchOU
+	OUs []string `json:"ous,omitempty"`
+}
+
+type filteredNotifier struct {
+	Notifier
+	filter NotifierFilter
+	// routeTag is the account tag with the destination of each account, see routeByAccountTag
+	routeTag string
+}
+
+// ParseNotifierSpec parses a notifier in the "<type>:<options>" format where options are URL
+// query parameters, the service, region, category, code and account parameters are filters
+// and accept multiple values, e.g.: slack:channel=C0123&category=issue&service=EC2,RDS
+func ParseNotifierSpec(spec string) (NotifierConfig, error) {
+	cfg := NotifierConfig{Options: make(map[string]string)}
+
+	kind, rawOptions, _ := strings.Cut(spec, ":")
+	cfg.Type = strings.TrimSpace(kind)
+	if cfg.Type == "" {
+		return cfg, fmt.Errorf("invalid notifier %q, format is <type>:<options>", spec)
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid notifier options %q: %w", spec, err)
+	}
+
+	for key, values := range options {
+		var split []string
+		for _, v := range values {
+			split = append(split, strings.Split(v, ",")...)
+		}
+
+		switch key {
+		case "service":
+			cfg.Filter.Services = append(cfg.Filter.Services, split...)
+		case "region":
+			cfg.Filter.Regions = append(cfg.Filter.Regions, split...)
+		case "category":
+			cfg.Filter.Categories = append(cfg.Filter.Categories, split...)
+		case "code":
+			cfg.Filter.EventTypeCodes = append(cfg.Filter.EventTypeCodes, split...)
+		case "account":
+			cfg.Filter.Accounts = append(cfg.Filter.Accounts, split...)
+		case "ou":
+			cfg.Filter.OUs = append(cfg.Filter.OUs, split...)
+		case "name":
+			cfg.Name = values[0]
+		default:
+			cfg.Options[key] = values[0]
+		}
+	}
+
+	return cfg, nil
+}
+
+// NewNotifier creates a notifier from its configuration
+func (m *Metrics) NewNotifier(cfg NotifierConfig) (Notifier, error) {
+	name := cfg.name()
+
+	switch cfg.Type {
+	case NotifierSlack:
+		return m.newSlackNotifier(name, cfg.Options)
+	case NotifierWebhook:
+		return m.newWebhookNotifier(name, cfg.Options)
+	case NotifierLog:
+		return m.newLogNotifier(name), nil
+	case NotifierTeams:
+		return m.newTeamsNotifier(name, cfg.Options)
+	case NotifierPagerDuty:
+		return m.newPagerDutyNotifier(name, cfg.Options)
+	case NotifierOpsgenie:
+		return m.newOpsgenieNotifier(name, cfg.Options)
+	case NotifierEmail:
+		return m.newEmailNotifier(name, cfg.Options)
+	default:
+		return nil, fmt.Errorf("unknown notifier type: %s", cfg.Type)
+	}
+}
+
+// notifierConfigs returns the notifiers configured by the legacy flags of each sink and the --notifier flag
+func (m *Metrics) notifierConfigs(c *cli.Context) ([]NotifierConfig, error) {
+	configs := make([]NotifierConfig, 0)
+
+	if len(c.String("slack-token")) > 0 && len(c.String("slack-channel")) > 0 {
+		configs = append(configs, NotifierConfig{
+			Type: NotifierSlack,
+			Options: map[string]string{
+				"channel":   c.String("slack-channel"),
+				"route-tag": c.String("slack-route-tag"),
+			},
+		})
+	}
+
+	if len(c.String("webhook-url")) > 0 {
+		options := map[string]string{
+			"url":      c.String("webhook-url"),
+			"template": c.String("webhook-template"),
+			"secret":   c.String("webhook-secret"),
+			"retries":  strconv.Itoa(c.Int("webhook-max-retries")),
+		}
+
+		headers, err := ParseWebhookHeaders(c.StringSlice("webhook-header"))
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range headers {
+			options["header."+name] = value
+		}
+
+		configs = append(configs, NotifierConfig{Type: NotifierWebhook, Options: options})
+	}
+
+	if len(c.String("teams-webhook-url")) > 0 {
+		configs = append(configs, NotifierConfig{
+			Type:    NotifierTeams,
+			Options: map[string]string{"url": c.String("teams-webhook-url")},
+		})
+	}
+
+	if len(c.String("pagerduty-routing-key")) > 0 {
+		configs = append(configs, NotifierConfig{
+			Type:    NotifierPagerDuty,
+			Options: map[string]string{"routing-key": c.String("pagerduty-routing-key")},
+		})
+	}
+
+	if len(c.String("opsgenie-api-key")) > 0 {
+		configs = append(configs, NotifierConfig{
+			Type: NotifierOpsgenie,
+			Options: map[string]string{
+				"api-key": c.String("opsgenie-api-key"),
+				"url":     c.String("opsgenie-api-url"),
+			},
+		})
+	}
+
+	if len(c.String("smtp-host")) > 0 {
+		configs = append(configs, NotifierConfig{
+			Type: NotifierEmail,
+			Options: map[string]string{
+				"host":            c.String("smtp-host"),
+				"port":            strconv.Itoa(c.Int("smtp-port")),
+				"username":        c.String("smtp-username"),
+				"password":        c.String("smtp-password"),
+				"from":            c.String("smtp-from"),
+				"to":              strings.Join(c.StringSlice("smtp-to"), ","),
+				"digest-interval": c.Duration("smtp-digest-interval").String(),
+				"route-tag":       c.String("smtp-route-tag"),
+			},
+		})
+	}
+
+	if c.Bool("log-events") {
+		configs = append(configs, NotifierConfig{Type: NotifierLog})
+	}
+
+	for _, spec := range c.StringSlice("notifier") {
+		cfg, err := ParseNotifierSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		configs = append(configs, cfg)
+	}
+
+	return configs, nil
+}
+
+func (m *Metrics) AddNotifier(cfg NotifierConfig) error {
+	for _, n := range m.notifiers {
+		if n.Name() == cfg.name() {
+			return fmt.Errorf("duplicate notifier name %q, notifiers of the same type require a unique name", cfg.name())
+		}
+	}
+
+	n, err := m.newFilteredNotifier(cfg)
+	if err != nil {
+		return err
+	}
+
+	m.notifiers = append(m.notifiers, n)
+	m.enableNotifierRequirements(n)
+
+	return nil
+}
+
+func (m *Metrics) newFilteredNotifier(cfg NotifierConfig) (filteredNotifier, error) {
+	n, err := m.NewNotifier(cfg)
+	if err != nil {
+		return filteredNotifier{}, err
+	}
+
+	// only page on-call for issues unless configured otherwise
+	if cfg.Type == NotifierPagerDuty && len(cfg.Filter.Categories) == 0 {
+		cfg.Filter.Categories = []string{string(healthTypes.EventTypeCategoryIssue)}
+	}
+
+	if stateful, ok := n.(statefulNotifier); ok {
+		if state, found := m.checkpoint.Notifiers[n.Name()]; found {
+			if err := stateful.LoadState(state); err != nil {
+				return filteredNotifier{}, fmt.Errorf("could not load state of notifier %s: %w", n.Name(), err)
+			}
+		}
+	}
+
+	routeTag := cfg.Options["route-tag"]
+	if _, ok := n.(routableNotifier); routeTag != "" && !ok {
+		return filteredNotifier{}, fmt.Errorf("notifier %s does not support routing by account tag", n.Name())
+	}
+
+	return filteredNotifier{Notifier: n, filter: cfg.Filter, routeTag: routeTag}, nil
+}
+
+// enableNotifierRequirements loads the organization data the notifier needs
+func (m *Metrics) enableNotifierRequirements(n filteredNotifier) {
+	if n.routeTag != "" {
+		m.loadAccountTags = true
+	}
+
+	if len(n.filter.OUs) > 0 {
+		m.loadOrgUnits = true
+	}
+}
+
+// notify sends the event to every notifier that accepts it, it returns false if any of them failed
+func (m *Metrics) notify(ctx context.Context, e HealthEvent) bool {
+	ok := true
+
+	for _, n := range m.notifiers {
+		filtered, match := m.applyFilter(n.filter, e)
+		if !match {
+			continue
+		}
+
+		// the changes only concern accounts filtered out for this notifier
+		if filtered.Changes != nil && filtered.Changes.empty() {
+			continue
+		}
+
+		var err error
+		if n.routeTag != "" {
+			err = m.routeByAccountTag(ctx, n.Notifier.(routableNotifier), n.routeTag, filtered)
+		} else {
+			err = n.Notify(ctx, filtered)
+		}
+
+		// queued events are counted when they are flushed
+		if err != nil || !batching(n.Notifier) {
+			m.recordNotification(ctx, n.Name(), err)
+		}
+		if err != nil {
+			m.recordError(ctx, fmt.Sprintf("Notify(%s)", n.Name()), err)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func batching(n Notifier) bool {
+	flushable, ok := n.(flushableNotifier)
+	return ok && flushable.Batching()
+}
+
+// flushNotifiers sends the events batched by notifiers
+func (m *Metrics) flushNotifiers(ctx context.Context) {
+	for _, n := range m.notifiers {
+		flushable, ok := n.Notifier.(flushableNotifier)
+		if !ok {
+			continue
+		}
+
+		sent, err := flushable.Flush(ctx)
+		for i := 0; i < sent; i++ {
+			m.recordNotification(ctx, n.Name(), nil)
+		}
+
+		if err != nil {
+			m.recordNotification(ctx, n.Name(), err)
+			m.recordError(ctx, fmt.Sprintf("Flush(%s)", n.Name()), err)
+		}
+	}
+}
+
+// applyFilter returns whether the event matches the filter, when filtering by account or OU the
+// returned event only contains the matching accounts and their resources
+func (m Metrics) applyFilter(f NotifierFilter, e HealthEvent) (HealthEvent, bool) {
+	if !matchAny(f.Services, aws.ToString(e.Event.Service)) ||
+		!matchAny(f.Regions, aws.ToString(e.Event.Region)) ||
+		!matchAny(f.Categories, string(e.Event.EventTypeCategory)) ||
+		!matchAny(f.EventTypeCodes, aws.ToString(e.Event.EventTypeCode)) {
+		return e, false
+	}
+
+	if len(f.Accounts) == 0 && len(f.OUs) == 0 {
+		return e, true
+	}
+
+	var accounts []string
+	for _, account := range e.AffectedAccounts {
+		if !matchAny(f.Accounts, account) && !matchAny(f.Accounts, m.accountNames[account]) {
+			continue
+		}
+
+		if len(f.OUs) > 0 && !matchAnyOU(f.OUs, m.accountOUs[account]) {
+			continue
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if len(accounts) == 0 {
+		return e, false
+	}
+
+	return e.withAccounts(accounts), true
+}
+
+// withAccounts returns a copy of the event limited to the given accounts and their resources
+func (e HealthEvent) withAccounts(accounts []string) HealthEvent {
+	keep := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		keep[account] = true
+	}
+
+	var resources []healthTypes.AffectedEntity
+	for _, r := range e.AffectedResources {
+		if r.AwsAccountId == nil || keep[*r.AwsAccountId] {
+			resources = append(resources, r)
+		}
+	}
+
+	e.AffectedAccounts = accounts
+	e.AffectedResources = resources
+
+	if e.Changes != nil {
+		changes := *e.Changes
+		changes.NewAccounts = intersect(changes.NewAccounts, accounts)
+		changes.NewResources = intersect(changes.NewResources, entityValues(resources))
+		e.Changes = &changes
+	}
+
+	return e
+}
+
+// accountKeys returns the affected accounts of the event, or a single empty account when the event
+// has no affected accounts, for the state kept and the alerts sent per account
+func (e HealthEvent) accountKeys() []string {
+	if len(e.AffectedAccounts) == 0 {
+		return []string{""}
+	}
+
+	return e.AffectedAccounts
+}
+
+// accountResources returns the name and the affected resources of an account of the event, see accountKeys
+func (m Metrics) accountResources(e HealthEvent, account string) (string, []healthTypes.AffectedEntity) {
+	if account == "" {
+		return "All accounts in region", e.AffectedResources
+	}
+
+	return m.extractAccounts([]string{account}), e.withAccounts([]string{account}).AffectedResources
+}
+
+// intersect returns the values that are also in list
+func intersect(values, list []string) []string {
+	keep := make(map[string]bool, len(list))
+	for _, v := range list {
+		keep[v] = true
+	}
+
+	var kept []string
+	for _, v := range values {
+		if keep[v] {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
+
+func matchAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
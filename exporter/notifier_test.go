@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNotifierSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want NotifierConfig
+	}{
+		{
+			spec: "log",
+			want: NotifierConfig{Type: "log", Options: map[string]string{}},
+		},
+		{
+			spec: "slack:channel=C0123&name=ops",
+			want: NotifierConfig{Type: "slack", Name: "ops", Options: map[string]string{"channel": "C0123"}},
+		},
+		{
+			spec: "slack:channel=C0123&category=issue&service=EC2,RDS&service=S3",
+			want: NotifierConfig{
+				Type:    "slack",
+				Options: map[string]string{"channel": "C0123"},
+				Filter:  NotifierFilter{Categories: []string{"issue"}, Services: []string{"EC2", "RDS", "S3"}},
+			},
+		},
+		{
+			spec: "email:host=smtp.example.com&to=a@example.com,b@example.com&region=us-east-1&code=AWS_EC2_X&account=prod,111111111111&ou=/Prod/*",
+			want: NotifierConfig{
+				Type:    "email",
+				Options: map[string]string{"host": "smtp.example.com", "to": "a@example.com,b@example.com"},
+				Filter: NotifierFilter{
+					Regions:        []string{"us-east-1"},
+					EventTypeCodes: []string{"AWS_EC2_X"},
+					Accounts:       []string{"prod", "111111111111"},
+					OUs:            []string{"/Prod/*"},
+				},
+			},
+		},
+		{
+			spec: "webhook:url=https%3A%2F%2Fexample.com%2Fhook%3Fa%3Db",
+			want: NotifierConfig{Type: "webhook", Options: map[string]string{"url": "https://example.com/hook?a=b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNotifierSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseNotifierSpec(%q) returned error: %v", tt.spec, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseNotifierSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseNotifierSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		":channel=C0123",
+		"slack:channel=%zz",
+	} {
+		if _, err := ParseNotifierSpec(spec); err == nil {
+			t.Errorf("ParseNotifierSpec(%q) did not return an error", spec)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	OpsgenieAPIURL string = "https://api.opsgenie.com"
+	// opsgenieMaxMessage is the maximum length of an alert message accepted by Opsgenie
+	opsgenieMaxMessage = 130
+)
+
+var opsgenieDefaultPriorities = map[healthTypes.EventTypeCategory]string{
+	healthTypes.EventTypeCategoryIssue:               "P2",
+	healthTypes.EventTypeCategoryInvestigation:       "P3",
+	healthTypes.EventTypeCategoryScheduledChange:     "P4",
+	healthTypes.EventTypeCategoryAccountNotification: "P5",
+}
+
+// OpsgenieNotifier creates one Opsgenie alert per event and account using the alias to
+// track it, adds a note when the event description changes and closes the alert when
+// the event is closed
+type OpsgenieNotifier struct {
+	name       string
+	m          *Metrics
+	client     *http.Client
+	url        string
+	apiKey     string
+	priorities map[healthTypes.EventTypeCategory]string
+
+	// last description sent for each open alert, by alias
+	descriptions map[string]string
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+}
+
+type opsgenieNote struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// newOpsgenieNotifier accepts the api-key, url (e.g. https://api.eu.opsgenie.com) and
+// priority.<category> (e.g. priority.issue=P1) options
+func (m *Metrics) newOpsgenieNotifier(name string, options map[string]string) (*OpsgenieNotifier, error) {
+	n := OpsgenieNotifier{
+		name:         name,
+		m:            m,
+		client:       m.httpClient,
+		url:          strings.TrimSuffix(options["url"], "/"),
+		apiKey:       options["api-key"],
+		priorities:   make(map[healthTypes.EventTypeCategory]string),
+		descriptions: make(map[string]string),
+	}
+
+	if n.apiKey == "" {
+		return nil, fmt.Errorf("opsgenie notifier %s requires an api-key", name)
+	}
+
+	if n.url == "" {
+		n.url = OpsgenieAPIURL
+	}
+
+	for category, priority := range opsgenieDefaultPriorities {
+		n.priorities[category] = priority
+	}
+
+	for key, value := range options {
+		if category, found := strings.CutPrefix(key, "priority."); found {
+			switch value {
+			case "P1", "P2", "P3", "P4", "P5":
+				n.priorities[healthTypes.EventTypeCategory(category)] = value
+			default:
+				return nil, fmt.Errorf("invalid opsgenie priority %s for %s", value, category)
+			}
+		}
+	}
+
+	return &n, nil
+}
+
+func (n *OpsgenieNotifier) Name() string {
+	return n.name
+}
+
+func (n *OpsgenieNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	accounts := e.accountKeys()
+
+	for _, account := range accounts {
+		if err := n.notifyAccount(ctx, e, account); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *OpsgenieNotifier) notifyAccount(ctx context.Context, e HealthEvent, account string) error {
+	alias := aws.ToString(e.Arn)
+	if account != "" {
+		alias = fmt.Sprintf("%s/%s", alias, account)
+	}
+
+	description := aws.ToString(e.EventDescription.LatestDescription)
+
+	if e.Event.StatusCode == healthTypes.EventStatusCodeClosed {
+		err := n.post(ctx, fmt.Sprintf("/v2/alerts/%s/close?identifierType=alias", url.PathEscape(alias)), opsgenieNote{
+			Source: "aws-health-exporter",
+			Note:   "AWS Health event is now resolved",
+		})
+		if err != nil {
+			return err
+		}
+
+		delete(n.descriptions, alias)
+		return nil
+	}
+
+	previous, known := n.descriptions[alias]
+	if !known {
+		if err := n.post(ctx, "/v2/alerts", n.alert(e, account, alias)); err != nil {
+			return err
+		}
+	} else if previous != description {
+		err := n.post(ctx, fmt.Sprintf("/v2/alerts/%s/notes?identifierType=alias", url.PathEscape(alias)), opsgenieNote{
+			Source: "aws-health-exporter",
+			Note:   e.updates(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	n.descriptions[alias] = description
+
+	return nil
+}
+
+func (n *OpsgenieNotifier) alert(e HealthEvent, account, alias string) opsgenieAlert {
+	m := n.m
+	service := aws.ToString(e.Event.Service)
+	region := aws.ToString(e.Event.Region)
+
+	accountName, resources := m.accountResources(e, account)
+
+	message := fmt.Sprintf("AWS Health: %s %s in %s (%s)", service, e.Event.EventTypeCategory, region, accountName)
+	if len(message) > opsgenieMaxMessage {
+		message = message[:opsgenieMaxMessage]
+	}
+
+	priority, ok := n.priorities[e.Event.EventTypeCategory]
+	if !ok {
+		priority = "P3"
+	}
+
+	return opsgenieAlert{
+		Message:     message,
+		Alias:       alias,
+		Description: aws.ToString(e.EventDescription.LatestDescription),
+		Details: map[string]string{
+			"Account(s)":  accountName,
+			"Resource(s)": m.extractResources(resources),
+			"Service":     service,
+			"Region":      region,
+			"Start Time":  e.Event.StartTime.In(m.tz).String(),
+			"Status":      string(e.Event.StatusCode),
+			"Event ARN":   aws.ToString(e.Event.Arn),
+		},
+		Tags:     []string{"aws-health", service, region, string(e.Event.EventTypeCategory)},
+		Entity:   service,
+		Source:   "aws-health-exporter",
+		Priority: priority,
+	}
+}
+
+func (n *OpsgenieNotifier) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+n.apiKey)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("opsgenie returned %s: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+// SaveState returns the descriptions of the open alerts so notes are not sent twice after a restart
+func (n *OpsgenieNotifier) SaveState() (json.RawMessage, error) {
+	return json.Marshal(n.descriptions)
+}
+
+func (n *OpsgenieNotifier) LoadState(state json.RawMessage) error {
+	return json.Unmarshal(state, &n.descriptions)
+}
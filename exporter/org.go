@@ -2,61 +2,120 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/health"
 	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	log "github.com/sirupsen/logrus"
 )
 
-func (m *Metrics) GetOrgEvents() []HealthEvent {
-	ctx := context.TODO()
+func (m *Metrics) GetOrgEvents(ctx context.Context) ([]HealthEvent, error) {
 	now := time.Now()
+	updatedEvents, retryFrom, err := m.describeOrgEvents(ctx, true, m.pushdownOrgFilter(&healthTypes.OrganizationEventFilter{
+		LastUpdatedTime: &healthTypes.DateTimeRange{
+			From: &m.lastScrape,
+			To:   &now,
+		},
+		Regions: m.regions,
+	}))
+	if err != nil {
+		// keep the previous window so these events are fetched again on the next poll
+		return nil, err
+	}
+
+	m.advanceLastScrape(now, retryFrom)
+
+	return updatedEvents, nil
+}
+
+// GetOpenOrgEvents returns all events that are currently open or upcoming, regardless of when they were last updated
+func (m *Metrics) GetOpenOrgEvents(ctx context.Context) ([]HealthEvent, error) {
+	events, _, err := m.describeOrgEvents(ctx, false, m.pushdownOrgFilter(&healthTypes.OrganizationEventFilter{
+		EventStatusCodes: []healthTypes.EventStatusCode{
+			healthTypes.EventStatusCodeOpen,
+			healthTypes.EventStatusCodeUpcoming,
+		},
+		Regions: m.regions,
+	}))
+
+	return events, err
+}
+
+// describeOrgEvents returns the enriched events matching the filter, events that could not be enriched
+// are skipped, when retry is set they count towards the retries of the poll and retryFrom is set to
+// the oldest update time among them
+func (m *Metrics) describeOrgEvents(ctx context.Context, retry bool, filter *healthTypes.OrganizationEventFilter) ([]HealthEvent, time.Time, error) {
+	var retryFrom time.Time
+
 	pag := health.NewDescribeEventsForOrganizationPaginator(
 		m.health,
-		&health.DescribeEventsForOrganizationInput{
-			Filter: &healthTypes.OrganizationEventFilter{
-				LastUpdatedTime: &healthTypes.DateTimeRange{
-					From: &m.lastScrape,
-					To:   &now,
-				},
-				Regions: m.regions,
-			},
-		})
+		&health.DescribeEventsForOrganizationInput{Filter: filter},
+	)
 
 	updatedEvents := make([]HealthEvent, 0)
 
 	for pag.HasMorePages() {
 		events, err := pag.NextPage(ctx)
 		if err != nil {
-			panic(err.Error())
+			m.recordError(ctx, "DescribeEventsForOrganization", err)
+			return nil, retryFrom, err
 		}
 
 		for _, event := range events.Events {
-			enrichedOrgEvent := m.EnrichOrgEvents(ctx, event)
+			if reason, skip := m.skipEvent(orgEventToEvent(event)); skip {
+				// ignored before DescribeAffectedAccountsForOrganization and DescribeAffectedEntitiesForOrganization
+				m.instruments.eventsFetched.Add(ctx, 1)
+				m.recordIgnored(ctx, reason)
+				m.filteredArns = append(m.filteredArns, aws.ToString(event.Arn))
+				continue
+			}
+
+			enrichedOrgEvent, err := m.EnrichOrgEvents(ctx, event)
+			if failoverError(err) {
+				// the region may be unavailable, failing the whole call lets the poll fail over
+				return nil, retryFrom, err
+			}
+
+			if err != nil {
+				if !retry {
+					log.WithError(err).Warnf("Skipping event %s, it could not be described", aws.ToString(event.Arn))
+				} else if m.retryEvent(ctx, aws.ToString(event.Arn), err) {
+					retryFrom = oldest(retryFrom, event.LastUpdatedTime)
+				}
+				continue
+			}
+			delete(m.retries, aws.ToString(event.Arn))
+
 			updatedEvents = append(updatedEvents, enrichedOrgEvent)
 		}
 	}
 
-	m.lastScrape = now
-
-	return updatedEvents
+	return updatedEvents, retryFrom, nil
 }
 
-func (m *Metrics) EnrichOrgEvents(ctx context.Context, event healthTypes.OrganizationEvent) HealthEvent {
+func (m *Metrics) EnrichOrgEvents(ctx context.Context, event healthTypes.OrganizationEvent) (HealthEvent, error) {
 
 	enrichedEvent := HealthEvent{Arn: event.Arn}
 
-	m.getAffectedAccountsForOrg(ctx, event, &enrichedEvent)
+	if err := m.getAffectedAccountsForOrg(ctx, event, &enrichedEvent); err != nil {
+		return enrichedEvent, err
+	}
 
-	m.getEventDetailsForOrg(ctx, event, &enrichedEvent)
+	if err := m.getEventDetailsForOrg(ctx, event, &enrichedEvent); err != nil {
+		return enrichedEvent, err
+	}
 
-	m.getAffectedEntitiesForOrg(ctx, event, &enrichedEvent)
+	if err := m.getAffectedEntitiesForOrg(ctx, event, &enrichedEvent); err != nil {
+		return enrichedEvent, err
+	}
 
-	return enrichedEvent
+	return enrichedEvent, nil
 }
 
-func (m Metrics) getAffectedAccountsForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) {
+func (m Metrics) getAffectedAccountsForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) error {
 	pag := health.NewDescribeAffectedAccountsForOrganizationPaginator(
 		m.health,
 		&health.DescribeAffectedAccountsForOrganizationInput{EventArn: event.Arn})
@@ -64,17 +123,20 @@ func (m Metrics) getAffectedAccountsForOrg(ctx context.Context, event healthType
 	for pag.HasMorePages() {
 		accounts, err := pag.NextPage(ctx)
 		if err != nil {
-			panic(err.Error())
+			m.recordError(ctx, "DescribeAffectedAccountsForOrganization", err)
+			return err
 		}
 
 		enrichedEvent.EventScope = accounts.EventScopeCode
 		enrichedEvent.AffectedAccounts = append(enrichedEvent.AffectedAccounts, accounts.AffectedAccounts...)
 	}
+
+	return nil
 }
 
-func (m Metrics) getEventDetailsForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) {
+func (m Metrics) getEventDetailsForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) error {
 	var accountId *string
-	if enrichedEvent.EventScope == healthTypes.EventScopeCodeAccountSpecific {
+	if enrichedEvent.EventScope == healthTypes.EventScopeCodeAccountSpecific && len(enrichedEvent.AffectedAccounts) > 0 {
 		accountId = &enrichedEvent.AffectedAccounts[0]
 	}
 
@@ -82,21 +144,33 @@ func (m Metrics) getEventDetailsForOrg(ctx context.Context, event healthTypes.Or
 		OrganizationEventDetailFilters: []healthTypes.EventAccountFilter{{EventArn: event.Arn, AwsAccountId: accountId}},
 	})
 	if err != nil {
-		panic(err.Error())
+		m.recordError(ctx, "DescribeEventDetailsForOrganization", err)
+		return err
+	}
+
+	if len(details.SuccessfulSet) == 0 {
+		err = fmt.Errorf("could not describe event details for %s", aws.ToString(event.Arn))
+		if len(details.FailedSet) > 0 {
+			err = fmt.Errorf("%w: %s", err, aws.ToString(details.FailedSet[0].ErrorMessage))
+		}
+		m.recordError(ctx, "DescribeEventDetailsForOrganization", err)
+		return err
 	}
 
 	enrichedEvent.Event = details.SuccessfulSet[0].Event
 	enrichedEvent.EventDescription = details.SuccessfulSet[0].EventDescription
+
+	return nil
 }
 
-func (m Metrics) getAffectedEntitiesForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) {
+func (m Metrics) getAffectedEntitiesForOrg(ctx context.Context, event healthTypes.OrganizationEvent, enrichedEvent *HealthEvent) error {
 	pagResources := make([]*health.DescribeAffectedEntitiesForOrganizationPaginator, 0)
 	if len(enrichedEvent.AffectedAccounts) > 0 {
 		affectedAccountsSlices := m.splitSlice(enrichedEvent.AffectedAccounts, 10)
 		for _, slice := range affectedAccountsSlices {
 			accountFilter := make([]healthTypes.EventAccountFilter, len(slice))
-			for i, account := range slice {
-				accountFilter[i] = healthTypes.EventAccountFilter{EventArn: event.Arn, AwsAccountId: &account}
+			for i := range slice {
+				accountFilter[i] = healthTypes.EventAccountFilter{EventArn: event.Arn, AwsAccountId: &slice[i]}
 			}
 
 			pagResources = append(pagResources, health.NewDescribeAffectedEntitiesForOrganizationPaginator(
@@ -117,33 +191,76 @@ func (m Metrics) getAffectedEntitiesForOrg(ctx context.Context, event healthType
 		for slices.HasMorePages() {
 			resources, err := slices.NextPage(ctx)
 			if err != nil {
-				panic(err.Error())
+				m.recordError(ctx, "DescribeAffectedEntitiesForOrganization", err)
+				return err
 			}
 
 			enrichedEvent.AffectedResources = append(enrichedEvent.AffectedResources, resources.Entities...)
 		}
 	}
+
+	return nil
 }
 
-func (m *Metrics) GetOrgAccountsName(ctx context.Context) {
+func (m *Metrics) GetOrgAccountsName(ctx context.Context) error {
 	org := organizations.NewFromConfig(m.awsconfig)
 	pag := organizations.NewListAccountsPaginator(
 		org,
 		&organizations.ListAccountsInput{},
 	)
 
-	m.accountNames = make(map[string]string, 0)
+	accountNames := make(map[string]string, 0)
 
 	for pag.HasMorePages() {
 		accounts, err := pag.NextPage(ctx)
 		if err != nil {
-			panic(err.Error())
+			m.recordError(ctx, "ListAccounts", err)
+			return err
 		}
 
 		for _, account := range accounts.Accounts {
-			m.accountNames[*account.Id] = *account.Name
+			accountNames[*account.Id] = *account.Name
+		}
+	}
+
+	m.accountNames = accountNames
+
+	if m.loadAccountTags {
+		return m.getOrgAccountsTags(ctx, org)
+	}
+
+	return nil
+}
+
+// getOrgAccountsTags loads the tags of every account, this is one API call per account
+func (m *Metrics) getOrgAccountsTags(ctx context.Context, org *organizations.Client) error {
+	accountTags := make(map[string]map[string]string, len(m.accountNames))
+
+	for id := range m.accountNames {
+		pag := organizations.NewListTagsForResourcePaginator(
+			org,
+			&organizations.ListTagsForResourceInput{ResourceId: aws.String(id)},
+		)
+
+		tags := make(map[string]string)
+		for pag.HasMorePages() {
+			page, err := pag.NextPage(ctx)
+			if err != nil {
+				m.recordError(ctx, "ListTagsForResource", err)
+				return err
+			}
+
+			for _, tag := range page.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
 		}
+
+		accountTags[id] = tags
 	}
+
+	m.accountTags = accountTags
+
+	return nil
 }
 
 func (m Metrics) getAccountsNameFromIds(ids []string) []string {
@@ -168,3 +285,107 @@ func (m Metrics) splitSlice(slice []string, batchSize int) [][]string {
 
 	return batches
 }
+
+// GetOrgUnits walks the organizational unit tree and stores the OU path of every
+// account (e.g. /Prod/TeamA), accounts directly under the root have the / path
+func (m *Metrics) GetOrgUnits(ctx context.Context) error {
+	org := organizations.NewFromConfig(m.awsconfig)
+	pag := organizations.NewListRootsPaginator(org, &organizations.ListRootsInput{})
+
+	accountOUs := make(map[string]string)
+
+	for pag.HasMorePages() {
+		roots, err := pag.NextPage(ctx)
+		if err != nil {
+			m.recordError(ctx, "ListRoots", err)
+			return err
+		}
+
+		for _, root := range roots.Roots {
+			if err := m.walkOrgUnit(ctx, org, aws.ToString(root.Id), "", accountOUs); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.accountOUs = accountOUs
+
+	return nil
+}
+
+func (m *Metrics) walkOrgUnit(ctx context.Context, org *organizations.Client, parentId, path string, accountOUs map[string]string) error {
+	accountPath := path
+	if accountPath == "" {
+		accountPath = "/"
+	}
+
+	accounts := organizations.NewListAccountsForParentPaginator(org, &organizations.ListAccountsForParentInput{ParentId: aws.String(parentId)})
+	for accounts.HasMorePages() {
+		page, err := accounts.NextPage(ctx)
+		if err != nil {
+			m.recordError(ctx, "ListAccountsForParent", err)
+			return err
+		}
+
+		for _, account := range page.Accounts {
+			accountOUs[aws.ToString(account.Id)] = accountPath
+		}
+	}
+
+	units := organizations.NewListOrganizationalUnitsForParentPaginator(org, &organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(parentId)})
+	for units.HasMorePages() {
+		page, err := units.NextPage(ctx)
+		if err != nil {
+			m.recordError(ctx, "ListOrganizationalUnitsForParent", err)
+			return err
+		}
+
+		for _, unit := range page.OrganizationalUnits {
+			if err := m.walkOrgUnit(ctx, org, aws.ToString(unit.Id), path+"/"+aws.ToString(unit.Name), accountOUs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// refreshOrganization loads the accounts names, tags and organizational units again
+// if they are older than the refresh interval
+func (m *Metrics) refreshOrganization(ctx context.Context) {
+	if !m.organizationEnabled || time.Since(m.organizationLoadedAt) < m.organizationRefresh {
+		return
+	}
+
+	m.loadOrganization(ctx)
+}
+
+func (m *Metrics) loadOrganization(ctx context.Context) {
+	m.organizationLoadedAt = time.Now()
+
+	if err := m.GetOrgAccountsName(ctx); err != nil {
+		log.WithError(err).Warn("Could not list organization accounts, using account ids instead of names and the default destination of notifiers")
+	}
+
+	if m.loadOrgUnits {
+		if err := m.GetOrgUnits(ctx); err != nil {
+			log.WithError(err).Warn("Could not walk the organizational units tree")
+		}
+	}
+}
+
+// orgEventToEvent returns the fields of an organization event that are also in an account event
+func orgEventToEvent(event healthTypes.OrganizationEvent) healthTypes.Event {
+	return healthTypes.Event{
+		Arn:               event.Arn,
+		EndTime:           event.EndTime,
+		EventScopeCode:    event.EventScopeCode,
+		EventTypeCategory: event.EventTypeCategory,
+		EventTypeCode:     event.EventTypeCode,
+		LastUpdatedTime:   event.LastUpdatedTime,
+		Region:            event.Region,
+		Service:           event.Service,
+		StartTime:         event.StartTime,
+		StatusCode:        event.StatusCode,
+	}
+}
@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	PagerDutyEventsURL string = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// PagerDutyNotifier triggers PagerDuty incidents with the Events API v2 and resolves
+// them when the AWS Health event is closed, there is one incident per event and account
+type PagerDutyNotifier struct {
+	name       string
+	m          *Metrics
+	client     *http.Client
+	url        string
+	routingKey string
+	severity   string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// newPagerDutyNotifier accepts the routing-key, severity (critical, error, warning or info) and url options
+func (m *Metrics) newPagerDutyNotifier(name string, options map[string]string) (*PagerDutyNotifier, error) {
+	n := PagerDutyNotifier{
+		name:       name,
+		m:          m,
+		client:     m.httpClient,
+		url:        options["url"],
+		routingKey: options["routing-key"],
+		severity:   options["severity"],
+	}
+
+	if n.routingKey == "" {
+		return nil, fmt.Errorf("pagerduty notifier %s requires a routing-key", name)
+	}
+
+	if n.url == "" {
+		n.url = PagerDutyEventsURL
+	}
+
+	switch n.severity {
+	case "":
+		n.severity = "critical"
+	case "critical", "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("invalid pagerduty severity: %s", n.severity)
+	}
+
+	return &n, nil
+}
+
+func (n *PagerDutyNotifier) Name() string {
+	return n.name
+}
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	accounts := e.accountKeys()
+
+	for _, account := range accounts {
+		if err := n.send(ctx, n.event(e, account)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *PagerDutyNotifier) event(e HealthEvent, account string) pagerDutyEvent {
+	dedupKey := aws.ToString(e.Arn)
+	if account != "" {
+		dedupKey = fmt.Sprintf("%s/%s", dedupKey, account)
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+	}
+
+	if e.Event.StatusCode == healthTypes.EventStatusCodeClosed {
+		event.EventAction = "resolve"
+		return event
+	}
+
+	m := n.m
+	service := aws.ToString(e.Event.Service)
+	region := aws.ToString(e.Event.Region)
+
+	accountName, resources := m.accountResources(e, account)
+
+	event.Payload = &pagerDutyPayload{
+		Summary:   fmt.Sprintf("AWS Health reported an issue with the %s service in the %s region (%s)", service, region, accountName),
+		Source:    "aws-health-exporter",
+		Severity:  n.severity,
+		Component: service,
+		Group:     region,
+		Class:     aws.ToString(e.Event.EventTypeCode),
+		CustomDetails: map[string]string{
+			"Account(s)":  accountName,
+			"Resource(s)": m.extractResources(resources),
+			"Service":     service,
+			"Region":      region,
+			"Start Time":  e.Event.StartTime.In(m.tz).String(),
+			"Status":      string(e.Event.StatusCode),
+			"Event ARN":   aws.ToString(e.Event.Arn),
+			"Updates":     e.updates(),
+		},
+	}
+
+	if e.Changes != nil {
+		event.Payload.CustomDetails["Changes"] = e.Changes.Summary()
+	}
+
+	if e.Event.StartTime != nil {
+		event.Payload.Timestamp = e.Event.StartTime.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+
+	return event
+}
+
+func (n *PagerDutyNotifier) send(ctx context.Context, event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pagerduty returned %s: %s", resp.Status, msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	log "github.com/sirupsen/logrus"
+)
+
+// maximum number of polls an event is retried when it could not be processed
+const maxEventRetries = 5
+
+// StartPoller periodically fetches AWS Health events in the background and
+// refreshes the event store, this decouples the AWS Health API traffic from
+// the Prometheus scrapes
+func (m *Metrics) StartPoller(ctx context.Context) {
+	log.Infof("Starting AWS Health poller [interval=%s]", m.pollInterval)
+
+	go m.watchConfig(ctx, m.configModTime)
+
+	go func() {
+		ticker := time.NewTicker(m.pollInterval)
+		defer ticker.Stop()
+
+		if m.backfill {
+			m.backfillEvents(ctx)
+		}
+
+		m.poll(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.poll(ctx)
+			case <-m.silences.changed:
+				// persist silences created or deleted by the API
+				m.saveCheckpoint(ctx)
+			case <-m.reload:
+				// applied between polls so the notifiers and filters are not used while replaced
+				m.reloadConfig(ctx)
+			}
+		}
+	}()
+}
+
+func (m *Metrics) poll(ctx context.Context) {
+	start := time.Now()
+
+	m.refreshHealthEndpoint(ctx)
+	m.refreshOrganization(ctx)
+
+	events, err := m.GetHealthEvents(ctx)
+	if failoverError(err) && m.failoverHealthEndpoint() {
+		events, err = m.GetHealthEvents(ctx)
+	}
+	m.recordPoll(ctx, start, err)
+	if err != nil {
+		log.WithError(err).Error("Could not poll AWS Health events, keeping the previous state")
+		return
+	}
+
+	now := time.Now()
+	m.updateStore(events, now)
+	m.store.Expire(now)
+
+	m.flushNotifiers(ctx)
+	m.saveCheckpoint(ctx)
+
+	log.Debugf("Polled AWS Health events [events=%d, duration=%s]", len(events), time.Since(start))
+}
+
+// backfillEvents seeds the event state with the events that were already open
+// or upcoming when the exporter started
+func (m *Metrics) backfillEvents(ctx context.Context) {
+	start := time.Now()
+	events, err := m.GetOpenHealthEvents(ctx, m.backfillNotify)
+	if failoverError(err) && m.failoverHealthEndpoint() {
+		events, err = m.GetOpenHealthEvents(ctx, m.backfillNotify)
+	}
+	if err != nil {
+		log.WithError(err).Error("Could not backfill open AWS Health events")
+		return
+	}
+
+	m.updateStore(events, time.Now())
+
+	m.saveCheckpoint(ctx)
+
+	log.Infof("Backfilled open AWS Health events [events=%d, notify=%t, duration=%s]", len(events), m.backfillNotify, time.Since(start))
+}
+
+// updateStore adds the events of a poll to the store and removes the events that were filtered out,
+// an event stored before it was filtered (e.g. a later update only affects ignored resources, or a
+// rule was added) would otherwise never be updated nor closed
+func (m *Metrics) updateStore(events []HealthEvent, now time.Time) {
+	m.store.Update(events, m.accountOUs, now)
+	m.store.Remove(m.filteredArns)
+	m.filteredArns = nil
+}
+
+// pruneStore applies the current filters to the stored events, it is called when the
+// configuration is reloaded
+func (m *Metrics) pruneStore(now time.Time) {
+	seen := make(map[string]bool)
+	var kept []HealthEvent
+
+	for _, stored := range m.store.Events() {
+		arn := aws.ToString(stored.Arn)
+		if seen[arn] {
+			continue
+		}
+		seen[arn] = true
+
+		// events of the regions removed from the configuration are not polled anymore
+		if !m.regionIncluded(aws.ToString(stored.Event.Region)) {
+			m.filteredArns = append(m.filteredArns, arn)
+			continue
+		}
+
+		e, _, keep := m.applyFilters(stored.HealthEvent)
+		if !keep {
+			m.filteredArns = append(m.filteredArns, arn)
+			continue
+		}
+
+		kept = append(kept, e)
+	}
+
+	m.updateStore(kept, now)
+}
+
+// retryEvent returns whether an event that could not be processed should be retried on the next poll,
+// an event failing maxEventRetries times is skipped so it does not hold the poll window forever
+func (m *Metrics) retryEvent(ctx context.Context, arn string, err error) bool {
+	m.retries[arn]++
+	if m.retries[arn] < maxEventRetries {
+		log.WithError(err).Warnf("Skipping event %s, it will be retried on the next poll [attempt=%d]", arn, m.retries[arn])
+		return true
+	}
+
+	delete(m.retries, arn)
+	m.recordError(ctx, "ProcessEvent", fmt.Errorf("giving up on event %s after %d attempts: %w", arn, maxEventRetries, err))
+
+	return false
+}
+
+// advanceLastScrape moves the poll window to now unless some events could not be
+// processed, in that case it starts from the oldest of them on the next poll
+func (m *Metrics) advanceLastScrape(now, retryFrom time.Time) {
+	if !retryFrom.IsZero() && retryFrom.Before(now) {
+		m.lastScrape = retryFrom
+		return
+	}
+
+	m.lastScrape = now
+}
+
+func oldest(current time.Time, t *time.Time) time.Time {
+	if t == nil {
+		return current
+	}
+
+	if current.IsZero() || t.Before(current) {
+		return *t
+	}
+
+	return current
+}
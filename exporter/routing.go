@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// routableNotifier is implemented by notifiers that can deliver an event to a destination
+// other than the configured one (e.g. another slack channel), an empty destination means
+// the configured one
+type routableNotifier interface {
+	NotifyDestination(ctx context.Context, e HealthEvent, destination string) error
+}
+
+// routeByAccountTag splits the event by the destination named in the routeTag tag of each
+// affected account, accounts without the tag and events without accounts use the default destination
+func (m *Metrics) routeByAccountTag(ctx context.Context, n routableNotifier, routeTag string, e HealthEvent) error {
+	if len(e.AffectedAccounts) == 0 {
+		return n.NotifyDestination(ctx, e, "")
+	}
+
+	destinations := make(map[string][]string)
+	for _, account := range e.AffectedAccounts {
+		destination := m.accountTags[account][routeTag]
+		destinations[destination] = append(destinations[destination], account)
+	}
+
+	// deterministic order, mostly to make logs easier to follow
+	keys := make([]string, 0, len(destinations))
+	for destination := range destinations {
+		keys = append(keys, destination)
+	}
+	sort.Strings(keys)
+
+	var failed error
+	for _, destination := range keys {
+		err := n.NotifyDestination(ctx, e.withAccounts(destinations[destination]), destination)
+		if err != nil {
+			failed = fmt.Errorf("could not notify %q: %w", destination, err)
+		}
+	}
+
+	return failed
+}
+
+// matchOU returns whether the OU path matches the pattern, a pattern ending with /* matches
+// the OU and everything under it, otherwise it is matched with path.Match
+func matchOU(pattern, ou string) bool {
+	if ou == "" {
+		return false
+	}
+
+	if prefix, found := strings.CutSuffix(pattern, "/*"); found {
+		return ou == prefix || prefix == "" || strings.HasPrefix(ou, prefix+"/")
+	}
+
+	match, _ := path.Match(pattern, ou)
+	return match
+}
+
+func matchAnyOU(patterns []string, ou string) bool {
+	for _, pattern := range patterns {
+		if matchOU(pattern, ou) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoreOUs removes the accounts in the ignored OUs from the event, it returns false
+// if all of its accounts are ignored
+func (m Metrics) ignoreOUs(e HealthEvent) (HealthEvent, bool) {
+	if len(m.ignoreOU) == 0 || len(e.AffectedAccounts) == 0 {
+		return e, true
+	}
+
+	var accounts []string
+	for _, account := range e.AffectedAccounts {
+		if !matchAnyOU(m.ignoreOU, m.accountOUs[account]) {
+			accounts = append(accounts, account)
+		}
+	}
+
+	if len(accounts) == 0 {
+		return e, false
+	}
+
+	if len(accounts) == len(e.AffectedAccounts) {
+		return e, true
+	}
+
+	return e.withAccounts(accounts), true
+}
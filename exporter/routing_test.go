@@ -0,0 +1,31 @@
+package exporter
+
+import "testing"
+
+func TestMatchOU(t *testing.T) {
+	tests := []struct {
+		pattern, ou string
+		want        bool
+	}{
+		{"/Prod/*", "/Prod", true},
+		{"/Prod/*", "/Prod/TeamA", true},
+		{"/Prod/*", "/Prod/TeamA/Service", true},
+		{"/Prod/*", "/Production", false},
+		{"/Prod/*", "/Dev/Prod", false},
+		{"/*", "/", true},
+		{"/*", "/Prod/TeamA", true},
+		{"/Prod", "/Prod", true},
+		{"/Prod", "/Prod/TeamA", false},
+		{"/Prod/Team?", "/Prod/TeamA", true},
+		{"/*/TeamA", "/Prod/TeamA", true},
+		{"/*/TeamA", "/Prod/Sub/TeamA", false},
+		{"/Prod/*", "", false},
+		{"*", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchOU(tt.pattern, tt.ou); got != tt.want {
+			t.Errorf("matchOU(%q, %q) = %v, want %v", tt.pattern, tt.ou, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	ReasonIgnoreEvents        string = "ignore_events"
+	ReasonIgnoreResources     string = "ignore_resources"
+	ReasonIgnoreResourceEvent string = "ignore_resource_event"
+	ReasonSuppressionRule     string = "suppression_rule"
+)
+
+// SuppressionRule suppresses the events matching an expression, e.g.:
+//
+//	service == EC2 and (region =~ "eu-.*" or account.name glob "sandbox-*")
+//
+// Comparisons are "<field> <operator> <value>" where operator is == or != (exact match),
+// =~ or !~ (regular expression matching the whole value) or glob (* and ? wildcards),
+// they can be combined with and, or, not and parentheses. Values with spaces, parentheses
+// or quotes must be quoted with double quotes.
+type SuppressionRule struct {
+	Expression string
+	// Reason is recorded in the events_ignored metric when this rule suppresses an event
+	Reason string
+
+	expr ruleExpr
+	// eventLevel rules only use fields of the event, not of its accounts or entities, so
+	// they can be evaluated before the event is enriched
+	eventLevel bool
+}
+
+// ParseSuppressionRule parses a suppression rule expression
+func ParseSuppressionRule(expression string) (SuppressionRule, error) {
+	return parseSuppressionRule(expression, ReasonSuppressionRule)
+}
+
+func parseSuppressionRule(expression, reason string) (SuppressionRule, error) {
+	tokens, err := lexRule(expression)
+	if err != nil {
+		return SuppressionRule{}, fmt.Errorf("invalid suppression rule %q: %w", expression, err)
+	}
+
+	p := ruleParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].value)
+	}
+	if err != nil {
+		return SuppressionRule{}, fmt.Errorf("invalid suppression rule %q: %w", expression, err)
+	}
+
+	return SuppressionRule{Expression: expression, Reason: reason, expr: expr, eventLevel: !p.targetFields}, nil
+}
+
+// legacyRules translates the --ignore-events, --ignore-resources and --ignore-resource-event
+// lists into suppression rules
+func legacyRules(cfg Config) ([]SuppressionRule, error) {
+	var rules []SuppressionRule
+
+	add := func(reason, format string, args ...interface{}) error {
+		rule, err := parseSuppressionRule(fmt.Sprintf(format, args...), reason)
+		if err != nil {
+			return err
+		}
+
+		rules = append(rules, rule)
+		return nil
+	}
+
+	for _, code := range cfg.IgnoreEvents {
+		if err := add(ReasonIgnoreEvents, "code == %s", strconv.Quote(code)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, resource := range cfg.IgnoreResources {
+		if err := add(ReasonIgnoreResources, "entity == %s", strconv.Quote(resource)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ignored := range cfg.IgnoreResourceEvents {
+		// resource identifiers may be ARNs, only the first colon separates the event type
+		code, resource, found := strings.Cut(ignored, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid ignored resource event %q, format is <event name>:<resource identifier>", ignored)
+		}
+
+		if err := add(ReasonIgnoreResourceEvent, "code == %s and entity == %s", strconv.Quote(code), strconv.Quote(resource)); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
+
+// suppressed returns the rule suppressing the event, an event is suppressed when each of its
+// targets (affected entities, and affected accounts without entities) matches a rule
+func (m Metrics) suppressed(e HealthEvent) (SuppressionRule, bool) {
+	if len(m.suppressions) == 0 {
+		return SuppressionRule{}, false
+	}
+
+	var first *SuppressionRule
+	for _, t := range m.ruleTargets(e) {
+		rule := matchRule(m.suppressions, t)
+		if rule == nil {
+			return SuppressionRule{}, false
+		}
+
+		if first == nil {
+			first = rule
+		}
+	}
+
+	return *first, true
+}
+
+func matchRule(rules []SuppressionRule, t ruleTarget) *SuppressionRule {
+	for i := range rules {
+		if rules[i].expr.eval(t) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+// ruleTarget is what rules are evaluated against, an affected entity or account of an event
+type ruleTarget struct {
+	event       *HealthEvent
+	account     string
+	accountName string
+	entity      *healthTypes.AffectedEntity
+}
+
+func (m Metrics) ruleTargets(e HealthEvent) []ruleTarget {
+	var targets []ruleTarget
+
+	withEntity := make(map[string]bool)
+	for i := range e.AffectedResources {
+		entity := &e.AffectedResources[i]
+		account := aws.ToString(entity.AwsAccountId)
+		withEntity[account] = true
+
+		targets = append(targets, ruleTarget{event: &e, account: account, accountName: m.accountNames[account], entity: entity})
+	}
+
+	for _, account := range e.AffectedAccounts {
+		if !withEntity[account] {
+			targets = append(targets, ruleTarget{event: &e, account: account, accountName: m.accountNames[account]})
+		}
+	}
+
+	if len(targets) == 0 {
+		targets = append(targets, ruleTarget{event: &e})
+	}
+
+	return targets
+}
+
+// ruleFields are the fields available to rules, entity.tags.<key> is also available
+var ruleFields = map[string]func(t ruleTarget) string{
+	"arn":      func(t ruleTarget) string { return aws.ToString(t.event.Arn) },
+	"service":  func(t ruleTarget) string { return aws.ToString(t.event.Event.Service) },
+	"region":   func(t ruleTarget) string { return aws.ToString(t.event.Event.Region) },
+	"category": func(t ruleTarget) string { return string(t.event.Event.EventTypeCategory) },
+	"code":     func(t ruleTarget) string { return aws.ToString(t.event.Event.EventTypeCode) },
+	"scope":    func(t ruleTarget) string { return string(t.event.Event.EventScopeCode) },
+	"status":   func(t ruleTarget) string { return string(t.event.Event.StatusCode) },
+	"account":  func(t ruleTarget) string { return t.account },
+	"account.name": func(t ruleTarget) string {
+		return t.accountName
+	},
+	"entity": func(t ruleTarget) string {
+		if t.entity == nil {
+			return ""
+		}
+		return aws.ToString(t.entity.EntityValue)
+	},
+	"entity.arn": func(t ruleTarget) string {
+		if t.entity == nil {
+			return ""
+		}
+		return aws.ToString(t.entity.EntityArn)
+	},
+	"entity.status": func(t ruleTarget) string {
+		if t.entity == nil {
+			return ""
+		}
+		return string(t.entity.StatusCode)
+	},
+}
+
+// eventRuleFields are the fields known before the event is enriched
+var eventRuleFields = map[string]bool{
+	"arn":      true,
+	"service":  true,
+	"region":   true,
+	"category": true,
+	"code":     true,
+	"scope":    true,
+	"status":   true,
+}
+
+const entityTagPrefix = "entity.tags."
+
+func ruleField(name string) (func(t ruleTarget) string, error) {
+	if f, ok := ruleFields[name]; ok {
+		return f, nil
+	}
+
+	if key := strings.TrimPrefix(name, entityTagPrefix); key != name && key != "" {
+		return func(t ruleTarget) string {
+			if t.entity == nil {
+				return ""
+			}
+			return t.entity.Tags[key]
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+type ruleExpr interface {
+	eval(t ruleTarget) bool
+}
+
+type ruleAnd struct{ left, right ruleExpr }
+
+func (r ruleAnd) eval(t ruleTarget) bool { return r.left.eval(t) && r.right.eval(t) }
+
+type ruleOr struct{ left, right ruleExpr }
+
+func (r ruleOr) eval(t ruleTarget) bool { return r.left.eval(t) || r.right.eval(t) }
+
+type ruleNot struct{ expr ruleExpr }
+
+func (r ruleNot) eval(t ruleTarget) bool { return !r.expr.eval(t) }
+
+type ruleComparison struct {
+	field  func(t ruleTarget) string
+	value  string
+	regexp *regexp.Regexp
+	negate bool
+}
+
+func (r ruleComparison) eval(t ruleTarget) bool {
+	v := r.field(t)
+
+	var match bool
+	if r.regexp != nil {
+		match = r.regexp.MatchString(v)
+	} else {
+		match = v == r.value
+	}
+
+	return match != r.negate
+}
+
+type ruleTokenKind int
+
+const (
+	ruleTokenWord ruleTokenKind = iota
+	ruleTokenString
+	ruleTokenOperator
+	ruleTokenOpen
+	ruleTokenClose
+)
+
+type ruleToken struct {
+	kind  ruleTokenKind
+	value string
+}
+
+var ruleOperators = []string{"==", "!=", "=~", "!~"}
+
+func lexRule(s string) ([]ruleToken, error) {
+	var tokens []ruleToken
+
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '(':
+			tokens = append(tokens, ruleToken{kind: ruleTokenOpen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, ruleToken{kind: ruleTokenClose, value: ")"})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+
+			value, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %w", s[i:end+1], err)
+			}
+
+			tokens = append(tokens, ruleToken{kind: ruleTokenString, value: value})
+			i = end + 1
+		case ruleOperatorAt(s, i) != "":
+			op := ruleOperatorAt(s, i)
+			tokens = append(tokens, ruleToken{kind: ruleTokenOperator, value: op})
+			i += len(op)
+		default:
+			end := i
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if unicode.IsSpace(r) || strings.ContainsRune(`()"`, r) || ruleOperatorAt(s, end) != "" {
+					break
+				}
+				end += size
+			}
+
+			tokens = append(tokens, ruleToken{kind: ruleTokenWord, value: s[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+func ruleOperatorAt(s string, i int) string {
+	for _, op := range ruleOperators {
+		if strings.HasPrefix(s[i:], op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	// targetFields is set when a comparison uses an account or entity field
+	targetFields bool
+}
+
+func (p *ruleParser) peek() *ruleToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+
+	return &p.tokens[p.pos]
+}
+
+// keyword returns true and consumes the next token if it is the given keyword
+func (p *ruleParser) keyword(keyword string) bool {
+	t := p.peek()
+	if t == nil || t.kind != ruleTokenWord || !strings.EqualFold(t.value, keyword) {
+		return false
+	}
+
+	p.pos++
+	return true
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = ruleOr{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = ruleAnd{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if p.keyword("not") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return ruleNot{expr}, nil
+	}
+
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if t.kind == ruleTokenOpen {
+		p.pos++
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := p.peek(); t == nil || t.kind != ruleTokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *ruleParser) parseComparison() (ruleExpr, error) {
+	t := p.peek()
+	if t.kind != ruleTokenWord {
+		return nil, fmt.Errorf("expected a field, got %q", t.value)
+	}
+	p.pos++
+
+	field, err := ruleField(t.value)
+	if err != nil {
+		return nil, err
+	}
+
+	if !eventRuleFields[t.value] {
+		p.targetFields = true
+	}
+
+	op := p.peek()
+	if op == nil || !(op.kind == ruleTokenOperator || op.kind == ruleTokenWord && strings.EqualFold(op.value, "glob")) {
+		return nil, fmt.Errorf("expected an operator after %q", t.value)
+	}
+	p.pos++
+
+	value := p.peek()
+	if value == nil || value.kind != ruleTokenWord && value.kind != ruleTokenString {
+		return nil, fmt.Errorf("expected a value after %q", op.value)
+	}
+	p.pos++
+
+	c := ruleComparison{field: field, value: value.value}
+
+	switch strings.ToLower(op.value) {
+	case "==":
+	case "!=":
+		c.negate = true
+	case "=~", "!~":
+		c.regexp, err = regexp.Compile("^(?:" + value.value + ")$")
+		if err != nil {
+			return nil, err
+		}
+		c.negate = op.value == "!~"
+	case "glob":
+		c.regexp = globRegexp(value.value)
+	}
+
+	return c, nil
+}
+
+// globRegexp converts a pattern where * matches any sequence and ? any character to a regexp
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
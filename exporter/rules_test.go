@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+func testRuleTarget() ruleTarget {
+	return ruleTarget{
+		event: &HealthEvent{
+			Arn: aws.String("arn:aws:health:eu-west-1::event/EC2/AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED/1"),
+			Event: &healthTypes.Event{
+				Service:           aws.String("EC2"),
+				Region:            aws.String("eu-west-1"),
+				EventTypeCategory: healthTypes.EventTypeCategoryScheduledChange,
+				EventTypeCode:     aws.String("AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED"),
+				EventScopeCode:    healthTypes.EventScopeCodeAccountSpecific,
+				StatusCode:        healthTypes.EventStatusCodeUpcoming,
+			},
+		},
+		account:     "111111111111",
+		accountName: "Zà prod",
+		entity: &healthTypes.AffectedEntity{
+			EntityValue: aws.String("i-0123456789"),
+			EntityArn:   aws.String("arn:aws:ec2:eu-west-1:111111111111:instance/i-0123456789"),
+			StatusCode:  healthTypes.EntityStatusCodeImpaired,
+			Tags:        map[string]string{"env": "dev"},
+		},
+	}
+}
+
+func TestLexRule(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []ruleToken
+	}{
+		{
+			expression: `service==EC2`,
+			want: []ruleToken{
+				{kind: ruleTokenWord, value: "service"},
+				{kind: ruleTokenOperator, value: "=="},
+				{kind: ruleTokenWord, value: "EC2"},
+			},
+		},
+		{
+			expression: `(code != "a (b) == c")`,
+			want: []ruleToken{
+				{kind: ruleTokenOpen, value: "("},
+				{kind: ruleTokenWord, value: "code"},
+				{kind: ruleTokenOperator, value: "!="},
+				{kind: ruleTokenString, value: "a (b) == c"},
+				{kind: ruleTokenClose, value: ")"},
+			},
+		},
+		{
+			expression: `entity == "say \"hi\""`,
+			want: []ruleToken{
+				{kind: ruleTokenWord, value: "entity"},
+				{kind: ruleTokenOperator, value: "=="},
+				{kind: ruleTokenString, value: `say "hi"`},
+			},
+		},
+		{
+			// the bytes of multi-byte characters are not spaces
+			expression: "account.name == Zà",
+			want: []ruleToken{
+				{kind: ruleTokenWord, value: "account.name"},
+				{kind: ruleTokenOperator, value: "=="},
+				{kind: ruleTokenWord, value: "Zà"},
+			},
+		},
+		{
+			expression: "region =~ eu-.*",
+			want: []ruleToken{
+				{kind: ruleTokenWord, value: "region"},
+				{kind: ruleTokenOperator, value: "=~"},
+				{kind: ruleTokenWord, value: "eu-.*"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := lexRule(tt.expression)
+		if err != nil {
+			t.Errorf("lexRule(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lexRule(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestSuppressionRuleEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		// comparisons
+		{`service == EC2`, true},
+		{`service == ec2`, false},
+		{`service != EC2`, false},
+		{`service != RDS`, true},
+		{`entity.tags.env == dev`, true},
+		{`entity.tags.owner == ""`, true},
+		{`account.name == Zà`, false},
+
+		// precedence: not binds tighter than and, and binds tighter than or
+		{`service == RDS and region == eu-west-1 or status == upcoming`, true},
+		{`service == RDS and (region == eu-west-1 or status == upcoming)`, false},
+		{`not service == EC2 or region == eu-west-1`, true},
+		{`not (service == EC2 or region == eu-west-1)`, false},
+		{`not not service == EC2`, true},
+		{`NOT service == RDS AND region == eu-west-1`, true},
+
+		// quoting
+		{`account.name == "Zà prod"`, true},
+		{`region == "eu-west-1"`, true},
+		{`code != "a (b) == c"`, true},
+
+		// glob
+		{`region glob "eu-*"`, true},
+		{`region glob eu-?est-1`, true},
+		{`account.name glob Zà*`, true},
+		{`entity glob i-0`, false},
+		{`entity glob "i.0*"`, false},
+		{`category GLOB "scheduled*"`, true},
+
+		// regexp, matching the whole value
+		{`region =~ "eu-.*"`, true},
+		{`region =~ west`, false},
+		{`region =~ ".*west.*"`, true},
+		{`region =~ "eu-west-1|us-east-1"`, true},
+		{`region !~ "us-.*"`, true},
+		{`entity.arn =~ "arn:aws:ec2:.*:instance/i-[0-9]+"`, true},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseSuppressionRule(tt.expression)
+		if err != nil {
+			t.Errorf("ParseSuppressionRule(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+
+		if got := rule.expr.eval(testRuleTarget()); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestParseSuppressionRuleErrors(t *testing.T) {
+	for _, expression := range []string{
+		``,
+		`service`,
+		`service ==`,
+		`service EC2`,
+		`== EC2`,
+		`unknown == EC2`,
+		`entity.tags. == dev`,
+		`service == EC2 and`,
+		`service == EC2 or or region == eu-west-1`,
+		`(service == EC2`,
+		`service == EC2)`,
+		`service == "EC2`,
+		`region =~ "("`,
+		`service == EC2 region == eu-west-1`,
+		`account.name == Zà prod`,
+	} {
+		if _, err := ParseSuppressionRule(expression); err == nil {
+			t.Errorf("ParseSuppressionRule(%q) did not return an error", expression)
+		}
+	}
+}
+
+func TestSuppressionRuleEventLevel(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`service == EC2 and region glob "eu-*"`, true},
+		{`not (code == A or status == closed)`, true},
+		{`service == EC2 and account == 111111111111`, false},
+		{`service == EC2 or entity.tags.env == dev`, false},
+		{`account.name glob "sandbox-*"`, false},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseSuppressionRule(tt.expression)
+		if err != nil {
+			t.Errorf("ParseSuppressionRule(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+
+		if rule.eventLevel != tt.want {
+			t.Errorf("%q event level = %v, want %v", tt.expression, rule.eventLevel, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package exporter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SilenceActive  string = "active"
+	SilencePending string = "pending"
+	SilenceExpired string = "expired"
+
+	silencesPath string = "/api/silences"
+)
+
+// Silence stops the notifications of the events matching all of its matchers until it expires,
+// the events are still exported as metrics
+type Silence struct {
+	ID string `json:"id"`
+	// Matchers are suppression rule expressions, see SuppressionRule
+	Matchers  []string  `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+	// Status is only set in API responses
+	Status string `json:"status,omitempty"`
+
+	rules []SuppressionRule
+}
+
+func (s *Silence) status(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return SilencePending
+	case now.Before(s.EndsAt):
+		return SilenceActive
+	default:
+		return SilenceExpired
+	}
+}
+
+func (s *Silence) compile() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("a silence requires at least one matcher")
+	}
+
+	s.rules = make([]SuppressionRule, 0, len(s.Matchers))
+	for _, matcher := range s.Matchers {
+		rule, err := ParseSuppressionRule(matcher)
+		if err != nil {
+			return err
+		}
+
+		s.rules = append(s.rules, rule)
+	}
+
+	return nil
+}
+
+func (s *Silence) match(t ruleTarget) bool {
+	for _, rule := range s.rules {
+		if !rule.expr.eval(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// silenceStore holds the silences, they are managed by the HTTP API while the poller reads them
+type silenceStore struct {
+	mu       sync.RWMutex
+	silences map[string]*Silence
+
+	// changed asks the poller to save the checkpoint
+	changed chan struct{}
+}
+
+func newSilenceStore() *silenceStore {
+	return &silenceStore{
+		silences: make(map[string]*Silence),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Load replaces the silences with the ones of a checkpoint
+func (s *silenceStore) Load(silences []Silence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.silences = make(map[string]*Silence, len(silences))
+	for i := range silences {
+		silence := silences[i]
+		if err := silence.compile(); err != nil {
+			log.WithError(err).Warnf("Dropping invalid silence %s", silence.ID)
+			continue
+		}
+
+		s.silences[silence.ID] = &silence
+	}
+}
+
+// List returns the silences sorted by expiration, expired for longer than retention are removed
+func (s *silenceStore) List(now time.Time, retention time.Duration) []Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silences := make([]Silence, 0, len(s.silences))
+	for id, silence := range s.silences {
+		if now.Sub(silence.EndsAt) > retention {
+			delete(s.silences, id)
+			continue
+		}
+
+		silences = append(silences, *silence)
+	}
+
+	sort.Slice(silences, func(i, j int) bool { return silences[i].EndsAt.Before(silences[j].EndsAt) })
+
+	return silences
+}
+
+// Active returns the number of active silences
+func (s *silenceStore) Active(now time.Time) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := 0
+	for _, silence := range s.silences {
+		if silence.status(now) == SilenceActive {
+			active++
+		}
+	}
+
+	return active
+}
+
+func (s *silenceStore) Add(silence Silence) {
+	s.mu.Lock()
+	s.silences[silence.ID] = &silence
+	s.mu.Unlock()
+
+	s.notifyChanged()
+}
+
+func (s *silenceStore) Delete(id string) bool {
+	s.mu.Lock()
+	_, found := s.silences[id]
+	delete(s.silences, id)
+	s.mu.Unlock()
+
+	if found {
+		s.notifyChanged()
+	}
+
+	return found
+}
+
+func (s *silenceStore) notifyChanged() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// silenced returns the active silence matching the event, an event is silenced when each of its
+// targets (see ruleTargets) matches all the matchers of the same silence
+func (m Metrics) silenced(e HealthEvent, now time.Time) (Silence, bool) {
+	m.silences.mu.RLock()
+	defer m.silences.mu.RUnlock()
+
+	targets := m.ruleTargets(e)
+
+	for _, silence := range m.silences.silences {
+		if silence.status(now) != SilenceActive {
+			continue
+		}
+
+		matched := true
+		for _, t := range targets {
+			if !silence.match(t) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return *silence, true
+		}
+	}
+
+	return Silence{}, false
+}
+
+type silenceRequest struct {
+	Matchers []string  `json:"matchers"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	// Duration is an alternative to EndsAt, e.g. 2h
+	Duration  string `json:"duration"`
+	CreatedBy string `json:"createdBy"`
+	Comment   string `json:"comment"`
+}
+
+func (r silenceRequest) silence(now time.Time) (Silence, error) {
+	silence := Silence{
+		Matchers:  r.Matchers,
+		StartsAt:  r.StartsAt,
+		EndsAt:    r.EndsAt,
+		CreatedBy: r.CreatedBy,
+		Comment:   r.Comment,
+	}
+
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+
+	if r.Duration != "" {
+		if !r.EndsAt.IsZero() {
+			return silence, fmt.Errorf("endsAt and duration are mutually exclusive")
+		}
+
+		d, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return silence, fmt.Errorf("invalid duration: %w", err)
+		}
+		silence.EndsAt = silence.StartsAt.Add(d)
+	}
+
+	if silence.EndsAt.IsZero() {
+		return silence, fmt.Errorf("a silence requires endsAt or duration")
+	}
+
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		return silence, fmt.Errorf("endsAt must be after startsAt and in the future")
+	}
+
+	if strings.TrimSpace(silence.CreatedBy) == "" || strings.TrimSpace(silence.Comment) == "" {
+		return silence, fmt.Errorf("a silence requires createdBy and comment")
+	}
+
+	if err := silence.compile(); err != nil {
+		return silence, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return silence, err
+	}
+	silence.ID = hex.EncodeToString(id)
+
+	return silence, nil
+}
+
+// SilencesHandler serves the silences API:
+//   - GET /api/silences lists the silences
+//   - POST /api/silences creates a silence
+//   - DELETE /api/silences/<id> deletes a silence
+func (m *Metrics) SilencesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, silencesPath), "/")
+
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			m.listSilences(w)
+		case id == "" && r.Method == http.MethodPost:
+			m.createSilence(w, r)
+		case id != "" && r.Method == http.MethodDelete:
+			m.deleteSilence(w, id)
+		case id == "":
+			w.Header().Set("Allow", "GET, POST")
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		default:
+			w.Header().Set("Allow", "DELETE")
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+	})
+}
+
+func (m *Metrics) listSilences(w http.ResponseWriter) {
+	now := time.Now()
+
+	silences := m.silences.List(now, m.store.retention)
+	for i := range silences {
+		silences[i].Status = silences[i].status(now)
+	}
+
+	writeAPIResponse(w, http.StatusOK, silences)
+}
+
+func (m *Metrics) createSilence(w http.ResponseWriter, r *http.Request) {
+	var req silenceRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid silence: %w", err))
+		return
+	}
+
+	now := time.Now()
+	silence, err := req.silence(now)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	m.silences.Add(silence)
+	log.Infof("Silence %s created by %s [matchers=%q, endsAt=%s, comment=%q]", silence.ID, silence.CreatedBy, silence.Matchers, silence.EndsAt, silence.Comment)
+
+	silence.Status = silence.status(now)
+	writeAPIResponse(w, http.StatusCreated, silence)
+}
+
+func (m *Metrics) deleteSilence(w http.ResponseWriter, id string) {
+	if !m.silences.Delete(id) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("silence %s not found", id))
+		return
+	}
+
+	log.Infof("Silence %s deleted", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Debug("Could not write API response")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -2,70 +2,139 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/health"
 	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+	log "github.com/sirupsen/logrus"
 )
 
-func (m *Metrics) GetAccountEvents() []HealthEvent {
-	ctx := context.TODO()
+func (m *Metrics) GetAccountEvents(ctx context.Context) ([]HealthEvent, error) {
 	now := time.Now()
+	updatedEvents, retryFrom, err := m.describeAccountEvents(ctx, true, m.pushdownAccountFilter(&healthTypes.EventFilter{
+		LastUpdatedTimes: []healthTypes.DateTimeRange{
+			{
+				From: &m.lastScrape,
+				To:   &now,
+			},
+		},
+		Regions: m.regions,
+	}))
+	if err != nil {
+		// keep the previous window so these events are fetched again on the next poll
+		return nil, err
+	}
+
+	m.advanceLastScrape(now, retryFrom)
+
+	return updatedEvents, nil
+}
+
+// GetOpenAccountEvents returns all events that are currently open or upcoming, regardless of when they were last updated
+func (m *Metrics) GetOpenAccountEvents(ctx context.Context) ([]HealthEvent, error) {
+	events, _, err := m.describeAccountEvents(ctx, false, m.pushdownAccountFilter(&healthTypes.EventFilter{
+		EventStatusCodes: []healthTypes.EventStatusCode{
+			healthTypes.EventStatusCodeOpen,
+			healthTypes.EventStatusCodeUpcoming,
+		},
+		Regions: m.regions,
+	}))
+
+	return events, err
+}
+
+// describeAccountEvents returns the enriched events matching the filter, events that could not be enriched
+// are skipped, when retry is set they count towards the retries of the poll and retryFrom is set to
+// the oldest update time among them
+func (m *Metrics) describeAccountEvents(ctx context.Context, retry bool, filter *healthTypes.EventFilter) ([]HealthEvent, time.Time, error) {
+	var retryFrom time.Time
+
 	pag := health.NewDescribeEventsPaginator(
 		m.health,
-		&health.DescribeEventsInput{
-			Filter: &healthTypes.EventFilter{
-				LastUpdatedTimes: []healthTypes.DateTimeRange{
-					{
-						From: &m.lastScrape,
-						To:   &now,
-					},
-				},
-				Regions: m.regions,
-			},
-		})
+		&health.DescribeEventsInput{Filter: filter},
+	)
 
 	updatedEvents := make([]HealthEvent, 0)
 
 	for pag.HasMorePages() {
 		events, err := pag.NextPage(ctx)
 		if err != nil {
-			panic(err.Error())
+			m.recordError(ctx, "DescribeEvents", err)
+			return nil, retryFrom, err
 		}
 
 		for _, event := range events.Events {
-			enrichedEvent := m.EnrichEvents(ctx, event)
+			if reason, skip := m.skipEvent(event); skip {
+				// ignored before DescribeEventDetails and DescribeAffectedEntities
+				m.instruments.eventsFetched.Add(ctx, 1)
+				m.recordIgnored(ctx, reason)
+				m.filteredArns = append(m.filteredArns, aws.ToString(event.Arn))
+				continue
+			}
+
+			enrichedEvent, err := m.EnrichEvents(ctx, event)
+			if failoverError(err) {
+				// the region may be unavailable, failing the whole call lets the poll fail over
+				return nil, retryFrom, err
+			}
+
+			if err != nil {
+				if !retry {
+					log.WithError(err).Warnf("Skipping event %s, it could not be described", aws.ToString(event.Arn))
+				} else if m.retryEvent(ctx, aws.ToString(event.Arn), err) {
+					retryFrom = oldest(retryFrom, event.LastUpdatedTime)
+				}
+				continue
+			}
+			delete(m.retries, aws.ToString(event.Arn))
+
 			updatedEvents = append(updatedEvents, enrichedEvent)
 		}
 	}
 
-	m.lastScrape = now
-
-	return updatedEvents
+	return updatedEvents, retryFrom, nil
 }
 
-func (m *Metrics) EnrichEvents(ctx context.Context, event healthTypes.Event) HealthEvent {
+func (m *Metrics) EnrichEvents(ctx context.Context, event healthTypes.Event) (HealthEvent, error) {
 
 	enrichedEvent := HealthEvent{Arn: event.Arn}
 
-	m.getEventDetails(ctx, event, &enrichedEvent)
+	if err := m.getEventDetails(ctx, event, &enrichedEvent); err != nil {
+		return enrichedEvent, err
+	}
 
-	m.getAffectedEntities(ctx, event, &enrichedEvent)
+	if err := m.getAffectedEntities(ctx, event, &enrichedEvent); err != nil {
+		return enrichedEvent, err
+	}
 
-	return enrichedEvent
+	return enrichedEvent, nil
 }
 
-func (m Metrics) getEventDetails(ctx context.Context, event healthTypes.Event, enrichedEvent *HealthEvent) {
+func (m Metrics) getEventDetails(ctx context.Context, event healthTypes.Event, enrichedEvent *HealthEvent) error {
 	details, err := m.health.DescribeEventDetails(ctx, &health.DescribeEventDetailsInput{EventArns: []string{*event.Arn}})
 	if err != nil {
-		panic(err.Error())
+		m.recordError(ctx, "DescribeEventDetails", err)
+		return err
+	}
+
+	if len(details.SuccessfulSet) == 0 {
+		err = fmt.Errorf("could not describe event details for %s", aws.ToString(event.Arn))
+		if len(details.FailedSet) > 0 {
+			err = fmt.Errorf("%w: %s", err, aws.ToString(details.FailedSet[0].ErrorMessage))
+		}
+		m.recordError(ctx, "DescribeEventDetails", err)
+		return err
 	}
 
 	enrichedEvent.Event = details.SuccessfulSet[0].Event
 	enrichedEvent.EventDescription = details.SuccessfulSet[0].EventDescription
+
+	return nil
 }
 
-func (m Metrics) getAffectedEntities(ctx context.Context, event healthTypes.Event, enrichedEvent *HealthEvent) {
+func (m Metrics) getAffectedEntities(ctx context.Context, event healthTypes.Event, enrichedEvent *HealthEvent) error {
 	pagResources := health.NewDescribeAffectedEntitiesPaginator(
 		m.health,
 		&health.DescribeAffectedEntitiesInput{Filter: &healthTypes.EntityFilter{EventArns: []string{*event.Arn}}})
@@ -73,11 +142,14 @@ func (m Metrics) getAffectedEntities(ctx context.Context, event healthTypes.Even
 	for pagResources.HasMorePages() {
 		resources, err := pagResources.NextPage(ctx)
 		if err != nil {
-			panic(err.Error())
+			m.recordError(ctx, "DescribeAffectedEntities", err)
+			return err
 		}
 
 		enrichedEvent.AffectedResources = append(enrichedEvent.AffectedResources, resources.Entities...)
 	}
 
 	enrichedEvent.EventScope = event.EventScopeCode
+
+	return nil
 }
@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+	"github.com/slack-go/slack"
+)
+
+type SlackNotifier struct {
+	name    string
+	m       *Metrics
+	api     *slack.Client
+	channel string
+
+	// first message of each open event, by channel and event ARN
+	threads map[string]slackThread
+}
+
+type slackThread struct {
+	Channel   string `json:"channel"`
+	Timestamp string `json:"ts"`
+}
+
+// newSlackNotifier accepts the channel and token options, the token defaults to --slack-token
+func (m *Metrics) newSlackNotifier(name string, options map[string]string) (*SlackNotifier, error) {
+	token := options["token"]
+	if token == "" {
+		token = m.slackToken
+	}
+
+	if token == "" || options["channel"] == "" {
+		return nil, fmt.Errorf("slack notifier %s requires a token and a channel", name)
+	}
+
+	return &SlackNotifier{
+		name:    name,
+		m:       m,
+		api:     slack.New(token),
+		channel: options["channel"],
+		threads: make(map[string]slackThread),
+	}, nil
+}
+
+func (n *SlackNotifier) Name() string {
+	return n.name
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	return n.NotifyDestination(ctx, e, "")
+}
+
+// NotifyDestination sends the event to another channel, the first notification of an event
+// is a new message and the following ones are replies in its thread, when the event is closed
+// the first message is also updated to show it is resolved
+func (n *SlackNotifier) NotifyDestination(ctx context.Context, e HealthEvent, channel string) error {
+	if channel == "" {
+		channel = n.channel
+	}
+
+	key := fmt.Sprintf("%s/%s", channel, aws.ToString(e.Arn))
+	closed := e.Event.StatusCode == healthTypes.EventStatusCodeClosed
+	text, attachment := n.message(e)
+
+	thread, found := n.threads[key]
+	if !found {
+		channelId, ts, err := n.api.PostMessageContext(
+			ctx,
+			channel,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionAttachments(attachment),
+		)
+		if err != nil {
+			return err
+		}
+
+		if !closed {
+			n.threads[key] = slackThread{Channel: channelId, Timestamp: ts}
+		}
+
+		return nil
+	}
+
+	_, _, err := n.api.PostMessageContext(
+		ctx,
+		thread.Channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAttachments(attachment),
+		slack.MsgOptionTS(thread.Timestamp),
+	)
+	if err != nil {
+		return err
+	}
+
+	if closed {
+		// the first message shows the whole event, not only what changed
+		e.Changes = nil
+		text, attachment = n.message(e)
+
+		_, _, _, err = n.api.UpdateMessageContext(
+			ctx,
+			thread.Channel,
+			thread.Timestamp,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionAttachments(attachment),
+		)
+		if err != nil {
+			return err
+		}
+
+		delete(n.threads, key)
+	}
+
+	return nil
+}
+
+func (n *SlackNotifier) message(e HealthEvent) (string, slack.Attachment) {
+	msg := n.m.eventMessage(e)
+
+	var fields []slack.AttachmentField
+	for _, f := range msg.Fields {
+		value := f.Value
+		if f.Code {
+			value = fmt.Sprintf("`%s`", value)
+		}
+		fields = append(fields, slack.AttachmentField{Title: f.Title, Value: value, Short: f.Short})
+	}
+	fields = append(fields, slack.AttachmentField{Title: "Updates", Value: msg.Updates, Short: false})
+
+	var text, color string
+	switch msg.Kind {
+	case MessageResolved:
+		text = fmt.Sprintf(":heavy_check_mark:*%s*", msg.Title)
+		color = "18be52"
+	case MessageUpdate:
+		text = fmt.Sprintf(":arrows_counterclockwise:*%s*", msg.Title)
+		color = "warning"
+	default:
+		text = fmt.Sprintf(":rotating_light:*%s*", msg.Title)
+		color = "danger"
+	}
+
+	attachment := slack.Attachment{
+		Color:  color,
+		Fields: fields,
+	}
+
+	return text, attachment
+}
+
+// SaveState returns the message of each open event so updates are still sent to
+// their threads after a restart
+func (n *SlackNotifier) SaveState() (json.RawMessage, error) {
+	return json.Marshal(n.threads)
+}
+
+func (n *SlackNotifier) LoadState(state json.RawMessage) error {
+	return json.Unmarshal(state, &n.threads)
+}
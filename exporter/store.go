@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+// eventKey identifies an event for a single affected account, account is empty
+// for events that are not account specific
+type eventKey struct {
+	Arn     string
+	Account string
+}
+
+type storedEvent struct {
+	HealthEvent
+	Account string
+	// OU is the organizational unit path of the account when known
+	OU       string
+	ClosedAt time.Time
+}
+
+// eventStore keeps the state of all known events gathered by the poller so
+// they can be read by the metrics callback without calling the AWS Health API
+// on every scrape, open events are kept until they are closed and closed events
+// are kept for the retention window
+type eventStore struct {
+	mu        sync.RWMutex
+	events    map[eventKey]*storedEvent
+	retention time.Duration
+}
+
+func newEventStore(retention time.Duration) *eventStore {
+	return &eventStore{
+		events:    make(map[eventKey]*storedEvent),
+		retention: retention,
+	}
+}
+
+// Update merges the events returned by a poll into the current state, accountOUs
+// is the OU path of each account
+func (s *eventStore) Update(events []HealthEvent, accountOUs map[string]string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range events {
+		arn := *e.Arn
+
+		accounts := e.accountKeys()
+
+		current := make(map[string]bool, len(accounts))
+		for _, account := range accounts {
+			current[account] = true
+		}
+
+		// accounts that are no longer affected by this event
+		for key := range s.events {
+			if key.Arn == arn && !current[key.Account] {
+				delete(s.events, key)
+			}
+		}
+
+		for _, account := range accounts {
+			key := eventKey{Arn: arn, Account: account}
+			stored := &storedEvent{HealthEvent: e, Account: account, OU: accountOUs[account]}
+
+			if e.Event.StatusCode == healthTypes.EventStatusCodeClosed {
+				if previous, ok := s.events[key]; ok && !previous.ClosedAt.IsZero() {
+					stored.ClosedAt = previous.ClosedAt
+				} else {
+					stored.ClosedAt = now
+				}
+			}
+
+			s.events[key] = stored
+		}
+	}
+}
+
+// Remove removes all the accounts of the given events
+func (s *eventStore) Remove(arns []string) {
+	if len(arns) == 0 {
+		return
+	}
+
+	remove := make(map[string]bool, len(arns))
+	for _, arn := range arns {
+		remove[arn] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.events {
+		if remove[key.Arn] {
+			delete(s.events, key)
+		}
+	}
+}
+
+// Expire removes closed events that are older than the retention window
+func (s *eventStore) Expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.events {
+		if !e.ClosedAt.IsZero() && now.Sub(e.ClosedAt) > s.retention {
+			delete(s.events, key)
+		}
+	}
+}
+
+// Events returns the events sorted by ARN and account
+func (s *eventStore) Events() []storedEvent {
+	s.mu.RLock()
+	events := make([]storedEvent, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, *e)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		if *events[i].Arn != *events[j].Arn {
+			return *events[i].Arn < *events[j].Arn
+		}
+
+		return events[i].Account < events[j].Account
+	})
+
+	return events
+}
@@ -0,0 +1,141 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+func testEvent(arn string, status healthTypes.EventStatusCode, accounts ...string) HealthEvent {
+	return HealthEvent{
+		Arn:              aws.String(arn),
+		AffectedAccounts: accounts,
+		Event: &healthTypes.Event{
+			Arn:        aws.String(arn),
+			Service:    aws.String("EC2"),
+			Region:     aws.String("us-east-1"),
+			StatusCode: status,
+		},
+	}
+}
+
+type storedState struct {
+	Account  string
+	Status   healthTypes.EventStatusCode
+	ClosedAt time.Time
+}
+
+func storeState(s *eventStore) map[string][]storedState {
+	state := make(map[string][]storedState)
+	for _, e := range s.Events() {
+		state[*e.Arn] = append(state[*e.Arn], storedState{Account: e.Account, Status: e.Event.StatusCode, ClosedAt: e.ClosedAt})
+	}
+
+	return state
+}
+
+func TestEventStore(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	t2 := t0.Add(2 * time.Hour)
+
+	open := healthTypes.EventStatusCodeOpen
+	closed := healthTypes.EventStatusCodeClosed
+
+	tests := []struct {
+		name string
+		run  func(s *eventStore)
+		want map[string][]storedState
+	}{
+		{
+			name: "one entry per account",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", open, "2", "1"), testEvent("b", open)}, nil, t0)
+			},
+			want: map[string][]storedState{
+				"a": {{Account: "1", Status: open}, {Account: "2", Status: open}},
+				"b": {{Account: "", Status: open}},
+			},
+		},
+		{
+			name: "accounts no longer affected are removed",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", open, "1", "2")}, nil, t0)
+				s.Update([]HealthEvent{testEvent("a", open, "2")}, nil, t1)
+			},
+			want: map[string][]storedState{
+				"a": {{Account: "2", Status: open}},
+			},
+		},
+		{
+			name: "closed time is kept on later updates",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", open, "1")}, nil, t0)
+				s.Update([]HealthEvent{testEvent("a", closed, "1")}, nil, t0)
+				s.Update([]HealthEvent{testEvent("a", closed, "1")}, nil, t1)
+			},
+			want: map[string][]storedState{
+				"a": {{Account: "1", Status: closed, ClosedAt: t0}},
+			},
+		},
+		{
+			name: "events not in a poll are kept",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", open, "1")}, nil, t0)
+				s.Update([]HealthEvent{testEvent("b", open, "1")}, nil, t1)
+			},
+			want: map[string][]storedState{
+				"a": {{Account: "1", Status: open}},
+				"b": {{Account: "1", Status: open}},
+			},
+		},
+		{
+			name: "remove drops every account",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", open, "1", "2"), testEvent("b", open, "1")}, nil, t0)
+				s.Remove([]string{"a", "unknown"})
+			},
+			want: map[string][]storedState{
+				"b": {{Account: "1", Status: open}},
+			},
+		},
+		{
+			name: "expire removes closed events older than the retention",
+			run: func(s *eventStore) {
+				s.Update([]HealthEvent{testEvent("a", closed, "1"), testEvent("b", open, "1")}, nil, t0)
+				s.Update([]HealthEvent{testEvent("c", closed, "1")}, nil, t2)
+				s.Expire(t2)
+			},
+			want: map[string][]storedState{
+				"b": {{Account: "1", Status: open}},
+				"c": {{Account: "1", Status: closed, ClosedAt: t2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newEventStore(time.Hour)
+		tt.run(s)
+
+		if got := storeState(s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventStoreOU(t *testing.T) {
+	s := newEventStore(time.Hour)
+	s.Update([]HealthEvent{testEvent("a", healthTypes.EventStatusCodeOpen, "1", "2")}, map[string]string{"1": "/Prod"}, time.Now())
+
+	ous := make(map[string]string)
+	for _, e := range s.Events() {
+		ous[e.Account] = e.OU
+	}
+
+	if want := map[string]string{"1": "/Prod", "2": ""}; !reflect.DeepEqual(ous, want) {
+		t.Errorf("got %v, want %v", ous, want)
+	}
+}
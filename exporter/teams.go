@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// TeamsNotifier sends AWS Health events as Adaptive Cards to a Microsoft Teams
+// incoming webhook or Workflows (Power Automate) URL
+type TeamsNotifier struct {
+	name   string
+	m      *Metrics
+	client *http.Client
+	url    string
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	ContentURL  *string      `json:"contentUrl"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	MSTeams map[string]string        `json:"msteams,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// newTeamsNotifier accepts the url option
+func (m *Metrics) newTeamsNotifier(name string, options map[string]string) (*TeamsNotifier, error) {
+	if options["url"] == "" {
+		return nil, fmt.Errorf("teams notifier %s requires an url", name)
+	}
+
+	return &TeamsNotifier{
+		name:   name,
+		m:      m,
+		client: m.httpClient,
+		url:    options["url"],
+	}, nil
+}
+
+func (n *TeamsNotifier) Name() string {
+	return n.name
+}
+
+func (n *TeamsNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	return n.NotifyDestination(ctx, e, "")
+}
+
+// NotifyDestination sends the event to another webhook URL
+func (n *TeamsNotifier) NotifyDestination(ctx context.Context, e HealthEvent, url string) error {
+	if url == "" {
+		url = n.url
+	}
+
+	body, err := json.Marshal(n.message(e))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("teams webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// message renders the same information as the slack notification
+func (n *TeamsNotifier) message(e HealthEvent) teamsMessage {
+	msg := n.m.eventMessage(e)
+
+	var facts []adaptiveFact
+	for _, f := range msg.Fields {
+		facts = append(facts, adaptiveFact{Title: f.Title, Value: f.Value})
+	}
+
+	var title, style, color string
+	switch msg.Kind {
+	case MessageResolved:
+		title = "✅ " + msg.Title
+		style = "good"
+		color = "Good"
+	case MessageUpdate:
+		title = "🔄 " + msg.Title
+		style = "warning"
+		color = "Warning"
+	default:
+		title = "🚨 " + msg.Title
+		style = "attention"
+		color = "Attention"
+	}
+
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		MSTeams: map[string]string{"width": "Full"},
+		Body: []map[string]interface{}{
+			{
+				"type":  "Container",
+				"style": style,
+				"bleed": true,
+				"items": []map[string]interface{}{
+					{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+				},
+			},
+			{"type": "FactSet", "facts": facts},
+			{"type": "TextBlock", "text": "Updates", "weight": "Bolder", "wrap": true},
+			{"type": "TextBlock", "text": msg.Updates, "wrap": true},
+		},
+	}
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{
+			{ContentType: "application/vnd.microsoft.card.adaptive", Content: card},
+		},
+	}
+}
@@ -1,35 +1,72 @@
 package exporter
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/health"
 	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
-	"github.com/slack-go/slack"
 )
 
 type Metrics struct {
-	health *health.Client
+	health          *health.Client
+	endpoint        *healthEndpoint
+	endpointRefresh time.Duration
 
-	slackApi     *slack.Client
-	slackToken   string
-	slackChannel string
+	notifiers  []filteredNotifier
+	httpClient *http.Client
+	slackToken string
 
 	tz         *time.Location
 	lastScrape time.Time
 
+	pollInterval time.Duration
+	store        *eventStore
+	// ARNs of the events filtered out during the current poll, see updateStore
+	filteredArns []string
+	// failed attempts to process each event, see retryEvent
+	retries map[string]int
+
+	backfill       bool
+	backfillNotify bool
+
+	checkpointStore CheckpointStore
+	checkpoint      *Checkpoint
+	silences        *silenceStore
+
+	instruments *instruments
+
 	awsconfig           aws.Config
 	organizationEnabled bool
 	regions             []string
 
-	ignoreEvents        []string
-	ignoreResources     []string
-	ignoreResourceEvent []string
+	// suppressions include the rules translated from the ignore flags, see legacyRules
+	suppressions []SuppressionRule
+	eventFilter  eventFilter
+
+	// the configuration of the flags, completed by configPath and reloaded by the poller
+	baseConfig          Config
+	configPath          string
+	configModTime       time.Time
+	configCheckInterval time.Duration
+	reload              chan struct{}
+
+	accountNames    map[string]string
+	accountTags     map[string]map[string]string
+	loadAccountTags bool
+
+	// OU path of each account, see GetOrgUnits
+	accountOUs   map[string]string
+	loadOrgUnits bool
+	exportOU     bool
+	ignoreOU     []string
 
-	accountNames map[string]string
+	organizationRefresh  time.Duration
+	organizationLoadedAt time.Time
 
-	logEvents bool
+	exportEntities bool
+	entitiesLimit  int
 }
 
 type HealthEvent struct {
@@ -39,4 +76,6 @@ type HealthEvent struct {
 	Event             *healthTypes.Event
 	EventDescription  *healthTypes.EventDescription
 	AffectedResources []healthTypes.AffectedEntity
+	// Changes since the last notification of this event, nil if it was never notified
+	Changes *EventChanges
 }
@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader string = "X-Signature-256"
+	webhookDefaultRetries         = 3
+	webhookInitialBackoff         = 1 * time.Second
+	webhookMaxBackoff             = 30 * time.Second
+)
+
+// webhookTemplateFuncs are available to the webhook payload template
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+func parseWebhookTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New("webhook").Funcs(webhookTemplateFuncs).Parse(string(data))
+}
+
+type WebhookNotifier struct {
+	name     string
+	client   *http.Client
+	url      string
+	template *template.Template
+	headers  map[string]string
+	secret   []byte
+	retries  int
+}
+
+// newWebhookNotifier accepts the url, template (path of a template file), secret, retries
+// and header.<name> options
+func (m *Metrics) newWebhookNotifier(name string, options map[string]string) (*WebhookNotifier, error) {
+	n := WebhookNotifier{
+		name:    name,
+		client:  m.httpClient,
+		url:     options["url"],
+		headers: make(map[string]string),
+		secret:  []byte(options["secret"]),
+		retries: webhookDefaultRetries,
+	}
+
+	if n.url == "" {
+		return nil, fmt.Errorf("webhook notifier %s requires an url", name)
+	}
+
+	if path := options["template"]; path != "" {
+		tmpl, err := parseWebhookTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse webhook template: %w", err)
+		}
+		n.template = tmpl
+	}
+
+	if retries := options["retries"]; retries != "" {
+		r, err := strconv.Atoi(retries)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook retries %q: %w", retries, err)
+		}
+		n.retries = r
+	}
+
+	for key, value := range options {
+		if header, found := strings.CutPrefix(key, "header."); found {
+			n.headers[header] = value
+		}
+	}
+
+	return &n, nil
+}
+
+// ParseWebhookHeaders parses headers in the "Name: value" format
+func ParseWebhookHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid webhook header %q, format is <name>: <value>", header)
+		}
+
+		parsed[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return parsed, nil
+}
+
+func (n *WebhookNotifier) Name() string {
+	return n.name
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, e HealthEvent) error {
+	return n.NotifyDestination(ctx, e, "")
+}
+
+// NotifyDestination sends the event to another URL
+func (n *WebhookNotifier) NotifyDestination(ctx context.Context, e HealthEvent, url string) error {
+	if url == "" {
+		url = n.url
+	}
+
+	body, err := n.payload(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := webhookInitialBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, url, body)
+		if err == nil || !retry || attempt >= n.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+	}
+}
+
+func (n *WebhookNotifier) payload(e HealthEvent) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(e)
+	}
+
+	var buf bytes.Buffer
+	if err := n.template.Execute(&buf, e); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// post sends the payload once and returns whether it is worth retrying on error
+func (n *WebhookNotifier) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range n.headers {
+		req.Header.Set(name, value)
+	}
+
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return retry, err
+}
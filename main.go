@@ -27,11 +27,55 @@ func main() {
 		&cli.StringFlag{Name: "log-level", Aliases: []string{"v"}, Usage: "Log level", Value: "info"},
 		&cli.StringFlag{Name: "slack-token", Usage: "Slack token", EnvVars: []string{"SLACK_TOKEN"}},
 		&cli.StringFlag{Name: "slack-channel", Usage: "Slack channel id", EnvVars: []string{"SLACK_CHANNEL"}},
+		&cli.StringFlag{Name: "webhook-url", Usage: "Send AWS Health events to this URL", EnvVars: []string{"WEBHOOK_URL"}},
+		&cli.StringFlag{Name: "webhook-template", Usage: "Path of a Go template used to render the webhook body, by default the event is sent as JSON"},
+		&cli.StringSliceFlag{Name: "webhook-header", Usage: "Header added to webhook requests (format: <name>: <value>), can be specified multiple times"},
+		&cli.StringFlag{Name: "webhook-secret", Usage: "Secret used to sign webhook requests with HMAC-SHA256", EnvVars: []string{"WEBHOOK_SECRET"}},
+		&cli.IntFlag{Name: "webhook-max-retries", Usage: "Number of retries of a failed webhook request", Value: 3},
+		&cli.StringFlag{Name: "teams-webhook-url", Usage: "Microsoft Teams incoming webhook or Workflows URL", EnvVars: []string{"TEAMS_WEBHOOK_URL"}},
+		&cli.StringFlag{Name: "pagerduty-routing-key", Usage: "PagerDuty Events API v2 routing key", EnvVars: []string{"PAGERDUTY_ROUTING_KEY"}},
+		&cli.StringFlag{Name: "opsgenie-api-key", Usage: "Opsgenie API integration key", EnvVars: []string{"OPSGENIE_API_KEY"}},
+		&cli.StringFlag{Name: "opsgenie-api-url", Usage: "Opsgenie API URL", Value: "https://api.opsgenie.com", EnvVars: []string{"OPSGENIE_API_URL"}},
+		&cli.StringFlag{Name: "smtp-host", Usage: "SMTP server used to send emails", EnvVars: []string{"SMTP_HOST"}},
+		&cli.IntFlag{Name: "smtp-port", Usage: "SMTP server port", Value: 587, EnvVars: []string{"SMTP_PORT"}},
+		&cli.StringFlag{Name: "smtp-username", Usage: "SMTP username", EnvVars: []string{"SMTP_USERNAME"}},
+		&cli.StringFlag{Name: "smtp-password", Usage: "SMTP password", EnvVars: []string{"SMTP_PASSWORD"}},
+		&cli.StringFlag{Name: "smtp-from", Usage: "Sender of the emails", EnvVars: []string{"SMTP_FROM"}},
+		&cli.StringSliceFlag{Name: "smtp-to", Usage: "Recipient of the emails, can be specified multiple times", EnvVars: []string{"SMTP_TO"}},
+		&cli.DurationFlag{Name: "smtp-digest-interval", Usage: "Send a digest of the events with this interval instead of one email per event", Value: 0},
+		&cli.StringSliceFlag{Name: "notifier", Usage: "Add a notifier (format: <type>:<options>, e.g. slack:channel=C0123&category=issue), can be specified multiple times"},
+		&cli.BoolFlag{Name: "load-account-tags", Usage: "Load the tags of organization accounts, it is enabled automatically when a notifier routes by account tag", Value: false},
+		&cli.StringFlag{Name: "slack-route-tag", Usage: "Send each account's events to the slack channel in this account tag, --slack-channel is used for accounts without it"},
+		&cli.StringFlag{Name: "smtp-route-tag", Usage: "Send each account's events to the email addresses (comma separated) in this account tag, --smtp-to is used for accounts without it"},
+		&cli.BoolFlag{Name: "organizational-units", Usage: "Walk the organizational units tree and add the OU of each account as the ou label of the event metric", Value: false},
+		&cli.StringFlag{Name: "ignore-ou", Usage: "Comma separated list of organizational unit paths whose accounts are ignored (e.g. /Sandbox/*)"},
+		&cli.DurationFlag{Name: "organization-refresh-interval", Usage: "Interval between reloads of the organization accounts, tags and organizational units", Value: 1 * time.Hour},
 		&cli.StringFlag{Name: "assume-role", Usage: "Assume another AWS IAM role", EnvVars: []string{"ASSUME_ROLE"}},
+		&cli.StringFlag{Name: "include-accounts", Usage: "Comma separated list of account ids or names, only events affecting these accounts are exported and notified"},
+		&cli.StringFlag{Name: "exclude-accounts", Usage: "Comma separated list of account ids or names whose events are not exported nor notified"},
+		&cli.StringFlag{Name: "include-services", Usage: "Comma separated list of services (e.g. EC2,RDS), only events of these services are exported and notified"},
+		&cli.StringFlag{Name: "exclude-services", Usage: "Comma separated list of services whose events are not exported nor notified"},
+		&cli.StringFlag{Name: "include-categories", Usage: "Comma separated list of event categories (issue, accountNotification, scheduledChange or investigation), only events of these categories are exported and notified"},
+		&cli.StringFlag{Name: "exclude-categories", Usage: "Comma separated list of event categories that are not exported nor notified"},
 		&cli.StringFlag{Name: "ignore-events", Usage: "Comma separated list of events to be ignored on all resources"},
 		&cli.StringFlag{Name: "ignore-resources", Usage: "Comma separated list of resources to be ignored on all events, format is dependant on resource type (some are ARN others are Name, check AWS docs)"},
 		&cli.StringFlag{Name: "ignore-resource-event", Usage: "Comma separated list of events to be ignored on a specific resource (format: <event name>:<resource identifier>)"},
+		&cli.StringSliceFlag{Name: "suppress", Usage: "Suppress the events matching this rule (e.g. service == EC2 and account.name glob \"sandbox-*\"), can be specified multiple times"},
+		&cli.BoolFlag{Name: "silences-api", Usage: "Serve the API to manage silences on /api/silences, it has no authentication", Value: false},
 		&cli.BoolFlag{Name: "log-events", Usage: "Log AWS Health events as JSON", Value: false},
+		&cli.BoolFlag{Name: "export-affected-entities", Usage: "Export the entities affected by each event as the affected_entity metric", Value: false},
+		&cli.IntFlag{Name: "affected-entities-limit", Usage: "Maximum number of affected_entity series exported", Value: 1000},
+		&cli.DurationFlag{Name: "poll-interval", Usage: "Interval between AWS Health API polls", Value: 1 * time.Minute},
+		&cli.DurationFlag{Name: "health-endpoint-refresh", Usage: "Interval between checks of the active AWS Health region", Value: 5 * time.Minute},
+		&cli.DurationFlag{Name: "closed-event-retention", Usage: "How long closed events are still exported as metrics", Value: 1 * time.Hour},
+		&cli.BoolFlag{Name: "backfill", Usage: "Load events that are already open or upcoming when the exporter starts", Value: false},
+		&cli.BoolFlag{Name: "backfill-notify", Usage: "Also send notifications for the events loaded by --backfill", Value: false},
+		&cli.StringFlag{Name: "state-backend", Usage: "Where to persist the last poll time and notified events (none, file or configmap)", Value: "none", EnvVars: []string{"STATE_BACKEND"}},
+		&cli.StringFlag{Name: "state-file", Usage: "Path of the state file when using the file state backend", Value: "aws-health-exporter-state.json", EnvVars: []string{"STATE_FILE"}},
+		&cli.StringFlag{Name: "state-configmap", Usage: "Name of the ConfigMap when using the configmap state backend", Value: "aws-health-exporter-state", EnvVars: []string{"STATE_CONFIGMAP"}},
+		&cli.StringFlag{Name: "state-namespace", Usage: "Namespace of the ConfigMap when using the configmap state backend, defaults to the pod namespace", EnvVars: []string{"STATE_NAMESPACE"}},
+		&cli.StringFlag{Name: "config", Usage: "Path of a YAML or JSON file with regions, filters and notifiers, reloaded on SIGHUP or when modified", EnvVars: []string{"CONFIG_FILE"}},
+		&cli.DurationFlag{Name: "config-check-interval", Usage: "Interval between checks of the config file modification time, 0 disables it", Value: 30 * time.Second},
 
 		&cli.DurationFlag{Name: "time-shift", Usage: "[INTERNAL] Apply a time delta to event filter instead of looking at previous scrape", Hidden: true, Value: 0 * time.Second},
 	}
@@ -58,12 +102,14 @@ func main() {
 			}
 			defer provider.Shutdown(ctx)
 
-			_, err = exporter.NewMetrics(ctx, otel.Meter("aws-health-exporter"), c)
+			m, err := exporter.NewMetrics(ctx, otel.Meter("aws-health-exporter"), c)
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			serveMetrics(c)
+			m.StartPoller(ctx)
+
+			serveMetrics(c, m)
 
 			return nil
 		},
@@ -100,9 +146,16 @@ func newMeter() (*metric.MeterProvider, error) {
 	return provider, nil
 }
 
-func serveMetrics(c *cli.Context) {
+func serveMetrics(c *cli.Context, m *exporter.Metrics) {
 	log.Infof("Starting metric http endpoint [address=%s, path=%s, regions=%s]", c.String("listen-address"), c.String("metrics-path"), c.String("regions"))
 	http.Handle(c.String("metrics-path"), promhttp.Handler())
+
+	if c.Bool("silences-api") {
+		log.Info("Serving the silences API on /api/silences")
+		http.Handle("/api/silences", m.SilencesHandler())
+		http.Handle("/api/silences/", m.SilencesHandler())
+	}
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`<html>